@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -21,7 +22,11 @@ var (
 	min   = flag.Bool("m", false, "minify results")
 	stand = flag.Bool("s", false, "standardize results to plain JSON")
 	diff  = flag.Bool("d", false, "display diffs instead of rewriting files")
-	list  = flag.Bool("l", false,
+	keys  = flag.Bool("k", false, "sort object members by name")
+	ijson = flag.Bool("i", false,
+		"check conformance to I-JSON (RFC 7493) instead of formatting",
+	)
+	list = flag.Bool("l", false,
 		"list files whose formatting differs from hujsonfmt's",
 	)
 	write = flag.Bool("w", false,
@@ -34,6 +39,8 @@ var (
 
 func usage() {
 	fmt.Fprintf(os.Stderr, "usage: hujsonfmt [flags] [path ...]\n")
+	fmt.Fprintf(os.Stderr, "\nWhen formatting (without -m, -s, or -i), arrays marked with\n")
+	fmt.Fprintf(os.Stderr, "a %q comment are sorted.\n\n", "// "+hujson.SortDirective)
 	flag.PrintDefaults()
 }
 
@@ -112,8 +119,14 @@ func processFile(info fs.FileInfo, filename string, in io.Reader) error {
 
 	output, err := processSrc(input)
 	if err != nil {
+		if errs := syntaxErrors(filename, src); errs != nil {
+			return errs
+		}
 		return err
 	}
+	if *ijson {
+		return ijsonProblems(filename, src)
+	}
 
 	switch {
 	case *diff:
@@ -153,21 +166,42 @@ func readFile(path string, in io.Reader) ([]byte, error) {
 }
 
 func processSrc(src []byte) ([]byte, error) {
-	var r []byte
-	var err error
+	v, err := hujson.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	if *keys {
+		v.SortObjects(nil, true)
+	}
 	switch {
 	case *min:
-		r, err = hujson.Minimize(src)
+		v.Minimize()
 	case *stand:
-		r, err = hujson.Standardize(src)
+		v.Standardize()
 	default:
-		r, err = hujson.Format(src)
-	}
-	if err != nil {
-		return nil, err
+		// Marked arrays are only sorted when formatting,
+		// since minimizing or standardizing removes the markers.
+		if !*ijson {
+			v.SortMarkedArrays()
+		}
+		v.Format()
 	}
 
-	return r, nil
+	return v.Pack(), nil
+}
+
+// syntaxErrors reports every syntax error in src, rather than just the first.
+// It returns nil if src has no syntax errors.
+func syntaxErrors(filename string, src []byte) error {
+	_, errs := hujson.ParseWithRecovery(src)
+	if len(errs) == 0 {
+		return nil
+	}
+	var lines []string
+	for _, err := range errs {
+		lines = append(lines, fmt.Sprintf("%s:%d:%d: %v", filename, err.Line, err.Column, err.Err))
+	}
+	return errors.New(strings.Join(lines, "\n"))
 }
 
 func printDiff(filename string, src, modified []byte) {
@@ -249,3 +283,21 @@ func backupFile(
 
 	return backupFile, nil
 }
+
+// ijsonProblems reports every way in which src does not conform to I-JSON.
+// It returns nil if src conforms.
+func ijsonProblems(filename string, src []byte) error {
+	v, err := hujson.Parse(src)
+	if err != nil {
+		return err
+	}
+	probs := v.CheckIJSON()
+	if len(probs) == 0 {
+		return nil
+	}
+	var lines []string
+	for _, p := range probs {
+		lines = append(lines, fmt.Sprintf("%s:%d:%d: %q: %v", filename, p.Position.Line, p.Position.Column, p.Pointer, p.Err))
+	}
+	return errors.New(strings.Join(lines, "\n"))
+}
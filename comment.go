@@ -0,0 +1,281 @@
+// Copyright (c) 2021 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hujson
+
+import (
+	"bytes"
+	"iter"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Comment is a single line or block comment within an Extra.
+type Comment struct {
+	// Kind is the kind of comment.
+	Kind CommentKind
+	// Text is the content of the comment without the delimiters
+	// (i.e., without "//" and the trailing newline, or "/*" and "*/").
+	Text string
+	// Raw is the comment exactly as it appears, including the delimiters.
+	Raw []byte
+	// Offset is the byte offset of the comment within the Extra containing it.
+	Offset int
+}
+
+// CommentKind is the kind of comment.
+type CommentKind byte
+
+const (
+	LineComment  CommentKind = '/' // a "//" comment until the end of the line
+	BlockComment CommentKind = '*' // a "/*" comment until the next "*/"
+)
+
+// NewLineComment constructs a line comment with the provided text,
+// which is the content after the "//" delimiter.
+// Newlines in text cannot be represented and are replaced by spaces,
+// and invalid UTF-8 is replaced by the Unicode replacement character.
+func NewLineComment(text string) Comment {
+	text = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(text)
+	text = strings.ToValidUTF8(text, "\uFFFD")
+	raw := make([]byte, 0, len(lineCommentStart)+len(text)+len(lineCommentEnd))
+	raw = append(append(append(raw, lineCommentStart...), text...), lineCommentEnd...)
+	return Comment{Kind: LineComment, Text: text, Raw: raw}
+}
+
+// NewBlockComment constructs a block comment with the provided text,
+// which is the content between the "/*" and "*/" delimiters.
+// Occurrences of "*/" in text cannot be represented and are replaced by "* /",
+// and invalid UTF-8 is replaced by the Unicode replacement character.
+func NewBlockComment(text string) Comment {
+	text = strings.ReplaceAll(text, "*/", "* /")
+	text = strings.ToValidUTF8(text, "\uFFFD")
+	raw := make([]byte, 0, len(blockCommentStart)+len(text)+len(blockCommentEnd))
+	raw = append(append(append(raw, blockCommentStart...), text...), blockCommentEnd...)
+	return Comment{Kind: BlockComment, Text: text, Raw: raw}
+}
+
+// End returns the byte offset immediately after the comment
+// within the Extra containing it.
+func (c Comment) End() int {
+	return c.Offset + len(c.Raw)
+}
+
+// Comments returns an iterator over the comments in b.
+// Iteration stops early if b contains invalid whitespace or comments.
+func (b Extra) Comments() iter.Seq[Comment] {
+	return func(yield func(Comment) bool) {
+		for n := consumeWhitespace(b); n < len(b); n += consumeWhitespace(b[n:]) {
+			nc := consumeComment(b[n:])
+			if nc <= 0 || !utf8.Valid(b[n:][:nc]) {
+				return
+			}
+			raw := b[n : n+nc : n+nc]
+			c := Comment{Raw: raw, Offset: n}
+			switch {
+			case bytes.HasPrefix(raw, lineCommentStart):
+				c.Kind = LineComment
+				c.Text = string(raw[len(lineCommentStart) : len(raw)-len(lineCommentEnd)])
+			default:
+				c.Kind = BlockComment
+				c.Text = string(raw[len(blockCommentStart) : len(raw)-len(blockCommentEnd)])
+			}
+			if !yield(c) {
+				return
+			}
+			n += nc
+		}
+	}
+}
+
+// AppendComment appends the comment to b.
+// If c.Raw is not a single valid comment, then the comment is
+// reconstructed from c.Kind and c.Text using NewLineComment or NewBlockComment.
+func (b Extra) AppendComment(c Comment) Extra {
+	if consumeComment(c.Raw) != len(c.Raw) || len(c.Raw) == 0 || !utf8.Valid(c.Raw) {
+		switch c.Kind {
+		case LineComment:
+			c = NewLineComment(c.Text)
+		default:
+			c = NewBlockComment(c.Text)
+		}
+	}
+	return append(b, c.Raw...)
+}
+
+// AppendWhitespace appends the whitespace characters in s to b
+// (i.e., spaces, tabs, carriage returns, and newlines),
+// ignoring all other characters.
+func (b Extra) AppendWhitespace(s string) Extra {
+	for i := 0; i < len(s); i++ {
+		if consumeWhitespace([]byte{s[i]}) == 1 {
+			b = append(b, s[i])
+		}
+	}
+	return b
+}
+
+// leadingComments returns the comments in b that belong to the succeeding
+// value, where the offset of each comment is relative to the start of b.
+func (b Extra) leadingComments() []Comment {
+	_, currStart := b.classifyComments()
+	comments := slices.Collect(b[currStart:].Comments())
+	for i := range comments {
+		comments[i].Offset += currStart
+	}
+	return comments
+}
+
+// trailingComments returns the comments in b that belong to the preceding value.
+func (b Extra) trailingComments() []Comment {
+	prevEnd, _ := b.classifyComments()
+	return slices.Collect(b[:prevEnd].Comments())
+}
+
+// setLeadingComment replaces the comments in b that belong to
+// the succeeding value with line comments for each line of text.
+func (b *Extra) setLeadingComment(text string) {
+	b.extractLeadingComments(false)
+	if text == "" {
+		return
+	}
+	var leading Extra
+	for _, line := range strings.Split(text, "\n") {
+		leading = leading.AppendComment(NewLineComment(" " + line))
+	}
+	b.injectLeadingComments(leading)
+}
+
+// setTrailingComment replaces the comments in b that belong to
+// the preceding value with a single line comment for text.
+func (b *Extra) setTrailingComment(text string) {
+	b.extractTrailingcomments(false)
+	if text == "" {
+		return
+	}
+	trailing := Extra(" ").AppendComment(NewLineComment(" " + text))
+	b.injectTrailingComments(trailing)
+}
+
+// LeadingComments returns the comments in BeforeExtra.
+// Offsets are relative to the start of BeforeExtra.
+//
+// For object members and array elements, use the LeadingComments method
+// on the Object or Array, which excludes comments associated with
+// the preceding member or element.
+func (v *Value) LeadingComments() []Comment {
+	return slices.Collect(v.BeforeExtra.Comments())
+}
+
+// TrailingComments returns the comments in AfterExtra.
+// Offsets are relative to the start of AfterExtra.
+//
+// For object members and array elements, use the TrailingComments method
+// on the Object or Array, which includes comments after the comma.
+func (v *Value) TrailingComments() []Comment {
+	return slices.Collect(v.AfterExtra.Comments())
+}
+
+// SetLeadingComment replaces BeforeExtra with
+// a line comment for each line of text.
+// An empty text removes the leading comments.
+// It is recommended that Format be called afterwards.
+func (v *Value) SetLeadingComment(text string) {
+	v.BeforeExtra = nil
+	v.BeforeExtra.setLeadingComment(text)
+	// Nothing precedes the comments, so no newline is needed to separate them.
+	v.BeforeExtra = bytes.TrimPrefix(v.BeforeExtra, newline)
+}
+
+// SetTrailingComment replaces AfterExtra with a single line comment
+// for text, where newlines are replaced by spaces.
+// An empty text removes the trailing comments.
+// It is recommended that Format be called afterwards.
+func (v *Value) SetTrailingComment(text string) {
+	v.AfterExtra = nil
+	v.AfterExtra.setTrailingComment(text)
+}
+
+// LeadingComments returns the comments associated with the i-th member
+// that precede its name. Offsets are relative to the start of
+// the BeforeExtra of the member name.
+//
+// The comments of a member are identified by index rather than by Value
+// since they are stored in Extras shared with the neighboring members.
+// For example, a line comment after the comma of the i-th member is stored
+// in the BeforeExtra of the (i+1)-th member (or the AfterExtra of the object),
+// and whether a comment in that Extra belongs to the i-th or (i+1)-th member
+// depends on whether it is on the same line as the comma.
+func (obj *Object) LeadingComments(i int) []Comment {
+	return obj.beforeExtraAt(i).leadingComments()
+}
+
+// TrailingComments returns the comments associated with the i-th member
+// that follow its value, which are the comments immediately before the comma
+// and the line comment following the comma on the same line.
+// Offsets are relative to the start of the Extra containing each comment.
+func (obj *Object) TrailingComments(i int) []Comment {
+	return append(slices.Collect(obj.Members[i].Value.AfterExtra.Comments()), obj.beforeExtraAt(i+1).trailingComments()...)
+}
+
+// SetLeadingComment replaces the leading comments of the i-th member
+// with a line comment for each line of text.
+// An empty text removes the leading comments.
+// It is recommended that Format be called afterwards.
+func (obj *Object) SetLeadingComment(i int, text string) {
+	obj.beforeExtraAt(i).setLeadingComment(text)
+}
+
+// SetTrailingComment replaces the trailing comments of the i-th member
+// with a single line comment for text, where newlines are replaced by spaces.
+// An empty text removes the trailing comments.
+// It is recommended that Format be called afterwards.
+func (obj *Object) SetTrailingComment(i int, text string) {
+	clearAfterExtra(&obj.Members[i].Value)
+	obj.beforeExtraAt(i + 1).setTrailingComment(text)
+}
+
+// LeadingComments returns the comments associated with the i-th element
+// that precede it. Offsets are relative to the start of its BeforeExtra.
+//
+// Elements are identified by index for the same reason as in
+// Object.LeadingComments: their comments are stored in Extras
+// shared with the neighboring elements.
+func (arr *Array) LeadingComments(i int) []Comment {
+	return arr.beforeExtraAt(i).leadingComments()
+}
+
+// TrailingComments returns the comments associated with the i-th element
+// that follow it, which are the comments immediately before the comma
+// and the line comment following the comma on the same line.
+// Offsets are relative to the start of the Extra containing each comment.
+func (arr *Array) TrailingComments(i int) []Comment {
+	return append(slices.Collect(arr.Elements[i].AfterExtra.Comments()), arr.beforeExtraAt(i+1).trailingComments()...)
+}
+
+// SetLeadingComment replaces the leading comments of the i-th element
+// with a line comment for each line of text.
+// An empty text removes the leading comments.
+// It is recommended that Format be called afterwards.
+func (arr *Array) SetLeadingComment(i int, text string) {
+	arr.beforeExtraAt(i).setLeadingComment(text)
+}
+
+// SetTrailingComment replaces the trailing comments of the i-th element
+// with a single line comment for text, where newlines are replaced by spaces.
+// An empty text removes the trailing comments.
+// It is recommended that Format be called afterwards.
+func (arr *Array) SetTrailingComment(i int, text string) {
+	clearAfterExtra(&arr.Elements[i])
+	arr.beforeExtraAt(i + 1).setTrailingComment(text)
+}
+
+// clearAfterExtra removes the AfterExtra of v,
+// preserving whether the last value is followed by a trailing comma.
+func clearAfterExtra(v *Value) {
+	if v.AfterExtra != nil {
+		v.AfterExtra = Extra{}
+	}
+}
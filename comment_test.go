@@ -0,0 +1,148 @@
+// Copyright (c) 2021 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hujson
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestComments(t *testing.T) {
+	v, err := Parse([]byte(`// Comment1
+{
+	// Comment2
+
+	// Comment3
+	/* Comment4 */ "foo": "bar" /* Comment5 */, // Comment6
+	// Comment7
+	"fizz": [
+		1, // Comment8
+		/* Comment9 */ 2,
+	],
+} // Comment10
+`))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	obj := v.Value.(*Object)
+	arr := obj.Members[1].Value.Value.(*Array)
+
+	texts := func(cs []Comment) (out []string) {
+		for _, c := range cs {
+			out = append(out, string(c.Kind)+string(c.Raw)+"|"+c.Text)
+		}
+		return out
+	}
+	tests := []struct {
+		name string
+		got  []Comment
+		want []string
+	}{
+		{"Value.LeadingComments", v.LeadingComments(), []string{"/// Comment1\n| Comment1"}},
+		{"Value.TrailingComments", v.TrailingComments(), []string{"/// Comment10\n| Comment10"}},
+		{"Object.LeadingComments(0)", obj.LeadingComments(0), []string{"/// Comment3\n| Comment3", "*/* Comment4 */| Comment4 "}},
+		{"Object.TrailingComments(0)", obj.TrailingComments(0), []string{"*/* Comment5 */| Comment5 ", "/// Comment6\n| Comment6"}},
+		{"Object.LeadingComments(1)", obj.LeadingComments(1), []string{"/// Comment7\n| Comment7"}},
+		{"Object.TrailingComments(1)", obj.TrailingComments(1), nil},
+		{"Array.LeadingComments(0)", arr.LeadingComments(0), nil},
+		{"Array.TrailingComments(0)", arr.TrailingComments(0), []string{"/// Comment8\n| Comment8"}},
+		{"Array.LeadingComments(1)", arr.LeadingComments(1), []string{"*/* Comment9 */| Comment9 "}},
+	}
+	for _, tt := range tests {
+		if diff := cmp.Diff(tt.want, texts(tt.got)); diff != "" {
+			t.Errorf("%s mismatch (-want +got):\n%s", tt.name, diff)
+		}
+	}
+	if got := obj.LeadingComments(0); got[0].Offset != 16 || got[1].Offset != 29 {
+		t.Errorf("Object.LeadingComments(0) offsets = %d, %d, want 16, 29", got[0].Offset, got[1].Offset)
+	}
+
+	v.SetLeadingComment("")
+	v.SetTrailingComment("Trailer")
+	obj.SetLeadingComment(0, "Line1\nLine2")
+	obj.SetTrailingComment(0, "")
+	obj.SetLeadingComment(1, "")
+	obj.SetTrailingComment(1, "Fizz")
+	arr.SetLeadingComment(0, "One")
+	arr.SetTrailingComment(0, "Uno")
+	arr.SetLeadingComment(1, "")
+	arr.SetTrailingComment(1, "Dos")
+	v.Format()
+	want := `{
+	// Comment2
+
+	// Line1
+	// Line2
+	"foo": "bar",
+	"fizz": [
+		// One
+		1, // Uno
+		2, // Dos
+	], // Fizz
+} // Trailer
+`
+	if diff := cmp.Diff(want, v.String()); diff != "" {
+		t.Errorf("Set comments mismatch (-want +got):\n%s", diff)
+	}
+
+	// Setting comments without formatting preserves trailing commas
+	// and does not insert a newline before the first comment.
+	v, err = Parse([]byte(`{"foo": [1, 2 /* Two */,] /* Foo */,}`))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	obj = v.Value.(*Object)
+	arr = obj.Members[0].Value.Value.(*Array)
+	v.SetLeadingComment("Top")
+	obj.SetTrailingComment(0, "Foo")
+	arr.SetTrailingComment(1, "Two")
+	want = "// Top\n{\"foo\": [1, 2, // Two\n], // Foo\n}"
+	if diff := cmp.Diff(want, v.String()); diff != "" {
+		t.Errorf("Set comments mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestExtraComments(t *testing.T) {
+	b := Extra(" // hello\n\t/* wor*ld */ /**/")
+	var got []Comment
+	for c := range b.Comments() {
+		got = append(got, c)
+	}
+	want := []Comment{
+		{Kind: LineComment, Text: " hello", Raw: []byte("// hello\n"), Offset: 1},
+		{Kind: BlockComment, Text: " wor*ld ", Raw: []byte("/* wor*ld */"), Offset: 11},
+		{Kind: BlockComment, Text: "", Raw: []byte("/**/"), Offset: 24},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Comments mismatch (-want +got):\n%s", diff)
+	}
+	if got[1].End() != 23 {
+		t.Errorf("Comment.End = %d, want 23", got[1].End())
+	}
+
+	// Iteration stops at invalid content.
+	var n int
+	for range Extra("/* a */ x /* b */").Comments() {
+		n++
+	}
+	if n != 1 {
+		t.Errorf("Comments on invalid Extra yielded %d comments, want 1", n)
+	}
+
+	b = Extra(nil).
+		AppendWhitespace("\n\tx").
+		AppendComment(NewLineComment(" line\nbreak")).
+		AppendWhitespace("\t").
+		AppendComment(NewBlockComment("a */ b\xff")).
+		AppendComment(Comment{Kind: LineComment, Text: "raw", Raw: []byte("bad")})
+	wantExtra := "\n\t// line break\n\t/*a * / b�*///raw\n"
+	if string(b) != wantExtra {
+		t.Errorf("constructed Extra = %q, want %q", b, wantExtra)
+	}
+	if !b.IsValid() {
+		t.Errorf("constructed Extra is invalid: %q", b)
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright (c) 2021 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hujson
+
+import (
+	"strconv"
+)
+
+// Diff computes a JSON Patch (per RFC 6902) that transforms from into to.
+// Applying the patch to from using Value.Patch results in a value that is
+// semantically equal to to.
+//
+// Object members and array elements that are unchanged produce no operations.
+// Array elements that are reordered are relocated with "move" operations,
+// such that they retain their original comments.
+// Values that are added or replaced are copied from to along with their
+// comments, which are preserved when the patch is applied.
+//
+// The patch is returned in the HuJSON format and is formatted.
+// It reports an error if either from or to is not valid HuJSON.
+func Diff(from, to Value) ([]byte, error) {
+	for _, v := range []Value{from, to} {
+		if _, err := Parse(v.Pack()); err != nil {
+			return nil, err
+		}
+	}
+	var d differ
+	d.diffAll(from.Value, to.Value)
+	patch := Value{Value: &Array{Elements: d.ops}}
+	patch.Format()
+	return patch.Pack(), nil
+}
+
+// differ accumulates the patch operations for a diff.
+type differ struct {
+	ops   []Value
+	tasks []diffTask // tasks for the value currently being diffed
+}
+
+// diffTask is either a patch operation or a pending diff of nested values.
+type diffTask struct {
+	op Value // patch operation if pending is false
+
+	pending  bool
+	path     string
+	from, to ValueTrimmed
+	comp     composite
+	i        int
+}
+
+// diffAll appends operations that transform from into to.
+// Nested values are diffed using an explicit stack of tasks rather than
+// recursion, such that operations are appended in depth-first order.
+func (d *differ) diffAll(from, to ValueTrimmed) {
+	stack := [][]diffTask{{{pending: true, from: from, to: to}}}
+	for len(stack) > 0 {
+		tasks := stack[len(stack)-1]
+		if len(tasks) == 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		t := tasks[0]
+		stack[len(stack)-1] = tasks[1:]
+		if !t.pending {
+			d.ops = append(d.ops, t.op)
+			continue
+		}
+		d.tasks = nil
+		d.diff(t.path, t.from, t.to, t.comp, t.i)
+		stack = append(stack, d.tasks)
+	}
+}
+
+// diffNested schedules a diff of from and to at the given path.
+func (d *differ) diffNested(path string, from, to ValueTrimmed, comp composite, i int) {
+	d.tasks = append(d.tasks, diffTask{pending: true, path: path, from: from, to: to, comp: comp, i: i})
+}
+
+// diff schedules operations that transform from into to at the given path.
+// The to value is located at index i of comp, which is nil for the root.
+func (d *differ) diff(path string, from, to ValueTrimmed, comp composite, i int) {
+	switch from := from.(type) {
+	case Literal:
+		if to, ok := to.(Literal); ok && equalLiteral(from, to) {
+			return
+		}
+	case *Object:
+		if to, ok := to.(*Object); ok {
+			d.diffObject(path, from, to)
+			return
+		}
+	case *Array:
+		if to, ok := to.(*Array); ok {
+			d.diffArray(path, from, to)
+			return
+		}
+	}
+
+	// The root value cannot be replaced, but adding to it replaces it.
+	if comp == nil {
+		d.appendOp("add", path, "", &Value{Value: to.clone()})
+		return
+	}
+	v := copyAt(comp, i)
+	d.appendOp("replace", path, "", &v)
+}
+
+func (d *differ) diffObject(path string, from, to *Object) {
+	// Duplicate names are resolved such that the last member takes precedence,
+	// which is consistent with the behavior of json.Unmarshal.
+	toIndexes := make(map[string]int)
+	for j, m := range to.Members {
+		toIndexes[m.Name.Value.(Literal).String()] = j
+	}
+	fromCounts := make(map[string]int)
+	for _, m := range from.Members {
+		fromCounts[m.Name.Value.(Literal).String()]++
+	}
+
+	// Update or remove existing members.
+	// A pointer always references the first member with a given name,
+	// so earlier duplicates are removed before the last one is updated.
+	for _, m := range from.Members {
+		name := m.Name.Value.(Literal).String()
+		namePath := string(appendPointerToken([]byte(path), name))
+		fromCounts[name]--
+		j, ok := toIndexes[name]
+		if !ok || fromCounts[name] > 0 {
+			d.appendOp("remove", namePath, "", nil)
+			continue
+		}
+		d.diffNested(namePath, m.Value.Value, to.Members[j].Value.Value, to, j)
+	}
+
+	// Add new members.
+	for j, m := range to.Members {
+		name := m.Name.Value.(Literal).String()
+		if _, ok := fromCounts[name]; ok || toIndexes[name] != j {
+			continue
+		}
+		v := copyAt(to, j)
+		d.appendOp("add", string(appendPointerToken([]byte(path), name)), "", &v)
+	}
+}
+
+func (d *differ) diffArray(path string, from, to *Array) {
+	fromKeys := minimizedKeys(from.Elements)
+	toKeys := minimizedKeys(to.Elements)
+
+	// Determine the source element in from for every element in to,
+	// where -1 indicates that the element must be added.
+	// Elements in the longest common subsequence are unchanged,
+	// identical elements outside of it are moved, and
+	// the remaining elements between unchanged elements are updated in place.
+	sources := make([]int, len(toKeys))
+	for j := range sources {
+		sources[j] = -1
+	}
+	used := make([]bool, len(fromKeys))
+	lcs := longestCommonSubsequence(fromKeys, toKeys)
+	for _, match := range lcs {
+		sources[match[1]] = match[0]
+		used[match[0]] = true
+	}
+	unused := make(map[string][]int)
+	for i, k := range fromKeys {
+		if !used[i] {
+			unused[k] = append(unused[k], i)
+		}
+	}
+	for j, k := range toKeys {
+		if sources[j] < 0 && len(unused[k]) > 0 {
+			sources[j], unused[k] = unused[k][0], unused[k][1:]
+			used[sources[j]] = true
+		}
+	}
+	var i0, j0 int
+	for _, match := range append(lcs, [2]int{len(fromKeys), len(toKeys)}) {
+		i, j := i0, j0
+		for {
+			for i < match[0] && used[i] {
+				i++
+			}
+			for j < match[1] && sources[j] >= 0 {
+				j++
+			}
+			if i == match[0] || j == match[1] {
+				break
+			}
+			sources[j] = i
+			used[i] = true
+		}
+		i0, j0 = match[0]+1, match[1]+1
+	}
+
+	// Remove unused elements in reverse order so that indexes remain stable.
+	for i := len(fromKeys) - 1; i >= 0; i-- {
+		if !used[i] {
+			d.appendOp("remove", path+"/"+strconv.Itoa(i), "", nil)
+		}
+	}
+
+	// Arrange the elements in order, adding, moving, or updating as necessary.
+	// Elements after index j of the patched array are the unplaced elements
+	// of from in their original order, so the current index of element i
+	// is j plus the number of unplaced elements before it.
+	unplaced := make(fenwickTree, len(fromKeys)+1)
+	for i := range fromKeys {
+		if used[i] {
+			unplaced.add(i, 1)
+		}
+	}
+	for j, i := range sources {
+		indexPath := path + "/" + strconv.Itoa(j)
+		if i < 0 {
+			v := copyAt(to, j)
+			d.appendOp("add", indexPath, "", &v)
+			continue
+		}
+		if k := j + unplaced.sum(i); k != j {
+			d.appendOp("move", indexPath, path+"/"+strconv.Itoa(k), nil)
+		}
+		unplaced.add(i, -1)
+		if fromKeys[i] != toKeys[j] {
+			d.diffNested(indexPath, from.Elements[i].Value, to.Elements[j].Value, to, j)
+		}
+	}
+}
+
+// fenwickTree is a binary indexed tree of counts,
+// supporting updates and prefix sums in O(log n) time.
+type fenwickTree []int
+
+// add adds delta to the count at index i.
+func (t fenwickTree) add(i, delta int) {
+	for i++; i < len(t); i += i & -i {
+		t[i] += delta
+	}
+}
+
+// sum returns the sum of the counts before index i.
+func (t fenwickTree) sum(i int) (n int) {
+	for ; i > 0; i -= i & -i {
+		n += t[i]
+	}
+	return n
+}
+
+// appendOp schedules a patch operation with the provided members,
+// where from and value are omitted if empty or nil.
+func (d *differ) appendOp(op, path, from string, value *Value) {
+	obj := &Object{Members: []ObjectMember{
+		{Name: Value{Value: String("op")}, Value: Value{Value: String(op)}},
+		{Name: Value{Value: String("path")}, Value: Value{Value: String(path)}},
+	}}
+	if from != "" {
+		obj.Members = append(obj.Members, ObjectMember{
+			Name:  Value{Value: String("from")},
+			Value: Value{Value: String(from)},
+		})
+	}
+	if value != nil {
+		insertAt(obj, obj.length(), *value)
+		obj.Members[obj.length()-1].Name.Value = String("value")
+	}
+	d.tasks = append(d.tasks, diffTask{op: Value{Value: obj}})
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2021 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hujson
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var testdataDiff = []struct {
+	from string
+	to   string
+	want string
+}{{
+	from: `{"a":1}`,
+	to:   `{"a":1}`,
+	want: `[]`,
+}, {
+	from: `1`,
+	to:   `"hello"`,
+	want: `[{"op": "add", "path": "", "value": "hello"}]`,
+}, {
+	from: `{"a":1,"b":2,"c":{"d":3}}`,
+	to:   `{"a":1,"c":{"d":4},"e":5}`,
+	want: `[
+	{"op": "remove", "path": "/b"},
+	{"op": "replace", "path": "/c/d", "value": 4},
+	{"op": "add", "path": "/e", "value": 5}
+]`,
+}, {
+	from: `{"a/b":1,"c~d":2}`,
+	to:   `{"a/b":2}`,
+	want: `[
+	{"op": "replace", "path": "/a~1b", "value": 2},
+	{"op": "remove", "path": "/c~0d"}
+]`,
+}, {
+	from: `{"a":1,"a":2,"b":3,"b":4}`,
+	to:   `{"a":5}`,
+	want: `[
+	{"op": "remove", "path": "/a"},
+	{"op": "replace", "path": "/a", "value": 5},
+	{"op": "remove", "path": "/b"},
+	{"op": "remove", "path": "/b"}
+]`,
+}, {
+	from: `[1,2,3]`,
+	to:   `[3,1,2]`,
+	want: `[{"op": "move", "path": "/0", "from": "/2"}]`,
+}, {
+	from: `[1,2,3,4]`,
+	to:   `[0,1,5,3]`,
+	want: `[
+	{"op": "remove", "path": "/3"},
+	{"op": "add", "path": "/0", "value": 0},
+	{"op": "replace", "path": "/2", "value": 5}
+]`,
+}, {
+	from: `[{"a":1},{"b":2}]`,
+	to:   `[{"b":2},{"a":2}]`,
+	want: `[
+	{"op": "remove", "path": "/0"},
+	{"op": "add", "path": "/1", "value": {"a": 2}}
+]`,
+}, {
+	from: `{"a":[1,2],"b":{}}`,
+	to:   `{"a":{},"b":[]}`,
+	want: `[
+	{"op": "replace", "path": "/a", "value": {}},
+	{"op": "replace", "path": "/b", "value": []}
+]`,
+}, {
+	from: `{
+	// Comment1
+	"a": 1, // Comment2
+}`,
+	to: `{
+	// Comment3
+	"a": 2, // Comment4
+	// Comment5
+	"b": [
+		// Comment6
+		3,
+	], // Comment7
+}`,
+	want: `[{
+	"op":   "replace",
+	"path": "/a",
+	// Comment3
+	"value": 2, // Comment4
+}, {
+	"op":   "add",
+	"path": "/b",
+	// Comment5
+	"value": [
+		// Comment6
+		3,
+	], // Comment7
+}]`,
+}, {
+	from: `[9007199254740992]`,
+	to:   `[9007199254740993]`,
+	want: `[{"op": "replace", "path": "/0", "value": 9007199254740993}]`,
+}, {
+	from: `{"x":"\ud800"}`,
+	to:   `{"x":"\udc00"}`,
+	want: `[{"op": "replace", "path": "/x", "value": "\udc00"}]`,
+}, {
+	from: `[1.0, "\u00e9"]`,
+	to:   "[1, \"\u00e9\"]",
+	want: `[]`,
+}}
+
+func TestDiff(t *testing.T) {
+	for _, tt := range testdataDiff {
+		t.Run("", func(t *testing.T) {
+			from, err := Parse([]byte(tt.from))
+			if err != nil {
+				t.Fatalf("Parse error: %v", err)
+			}
+			to, err := Parse([]byte(tt.to))
+			if err != nil {
+				t.Fatalf("Parse error: %v", err)
+			}
+			patch, err := Diff(from, to)
+			if err != nil {
+				t.Fatalf("Diff error: %v", err)
+			}
+			if diff := cmp.Diff(tt.want+"\n", string(patch)); diff != "" {
+				t.Errorf("Diff mismatch (-want +got):\n%s", diff)
+			}
+			if err := from.Patch(patch); err != nil {
+				t.Fatalf("Patch error: %v", err)
+			}
+			if !equalValue(from, to) {
+				t.Errorf("Patch result mismatch:\ngot  %s\nwant %s", from, to)
+			}
+		})
+	}
+}
+
+func TestDiffRandom(t *testing.T) {
+	random := func(r *rand.Rand) Value {
+		elems := make([]Value, r.IntN(20))
+		for i := range elems {
+			elems[i] = Value{Value: Int(int64(r.IntN(8)))}
+		}
+		return Value{Value: &Array{Elements: elems}}
+	}
+	r := rand.New(rand.NewPCG(0, 0))
+	for range 1000 {
+		from, to := random(r), random(r)
+		patch, err := Diff(from, to)
+		if err != nil {
+			t.Fatalf("Diff error: %v", err)
+		}
+		got := from.Clone()
+		if err := got.Patch(patch); err != nil {
+			t.Fatalf("Patch(%s, %s) error: %v", from, patch, err)
+		}
+		if !equalValue(got, to) {
+			t.Fatalf("Patch(%s, %s) = %s, want %s", from, patch, got, to)
+		}
+	}
+}
+
+func BenchmarkDiff(b *testing.B) {
+	const n = 10000
+	from := &Array{}
+	for i := range n {
+		from.Elements = append(from.Elements, Value{Value: Int(int64(i))})
+	}
+	edited := Value{Value: from}.Clone()
+	arr := edited.Value.(*Array)
+	arr.Elements = slices.Delete(arr.Elements, 10, 20)
+	arr.Elements = slices.Insert(arr.Elements, n/2, Value{Value: Int(-1)})
+	arr.Elements[n/2+100], arr.Elements[n/2+200] = arr.Elements[n/2+200], arr.Elements[n/2+100]
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := Diff(Value{Value: from}, edited); err != nil {
+			b.Fatalf("Diff error: %v", err)
+		}
+	}
+}
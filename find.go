@@ -6,18 +6,26 @@ package hujson
 
 import (
 	"bytes"
+	"cmp"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
-var errNotFound = fmt.Errorf("value not found")
+var (
+	errNotFound        = fmt.Errorf("value not found")
+	errIndexLiteral    = fmt.Errorf("cannot index into literal")
+	errMissingSlash    = fmt.Errorf("lacks a forward slash prefix")
+	errInvalidArrayIdx = fmt.Errorf("invalid array index")
+)
 
 // Find locates the value specified by the JSON pointer (see RFC 6901).
 // It returns nil if the value does not exist or the pointer is invalid.
 // If a JSON object has multiple members matching a given name,
-// the first is returned. Object names are matched exactly,
+// the first is returned (see DuplicateNames). Object names are matched exactly,
 // rather than with a case-insensitive match.
 func (v *Value) Find(ptr string) *Value {
 	if s, err := v.find(findState{pointer: ptr}); err == nil {
@@ -44,13 +52,13 @@ func (v *Value) find(s findState) (findState, error) {
 	}
 	comp, ok := v.Value.(composite)
 	if !ok {
-		return s, fmt.Errorf("invalid pointer: cannot index into literal at %v", s.pointer[:s.offset])
+		return s, fmt.Errorf("invalid pointer: %w at %v", errIndexLiteral, s.pointer[:s.offset])
 	}
 
 	// There must be one or more fragments.
 	s.parent, s.idx, s.name = nil, 0, ""
 	if !strings.HasPrefix(s.pointer[s.offset:], "/") {
-		return s, fmt.Errorf("invalid pointer: lacks a forward slash prefix")
+		return s, fmt.Errorf("invalid pointer: %w", errMissingSlash)
 	}
 	n := len("/")
 	if i := strings.IndexByte(s.pointer[s.offset+n:], '/'); i >= 0 {
@@ -84,7 +92,7 @@ func (v *Value) find(s findState) (findState, error) {
 		}
 		i, err := strconv.ParseUint(name, 10, 0)
 		if err != nil || (i == 0 && name != "0") {
-			return s, fmt.Errorf("invalid array index: %s", name)
+			return s, fmt.Errorf("%w: %s", errInvalidArrayIdx, name)
 		}
 		if i < uint64(len(comp.Elements)) {
 			s.idx = int(i)
@@ -95,8 +103,8 @@ func (v *Value) find(s findState) (findState, error) {
 }
 
 func (b Literal) equalString(s string) bool {
-	// Fast-path: Assume there are no escape characters.
-	if len(b) >= 2 && b[0] == '"' && b[len(b)-1] == '"' && bytes.IndexByte(b, '\\') < 0 {
+	// Fast-path: Assume there are no escape characters or invalid UTF-8.
+	if len(b) >= 2 && b[0] == '"' && b[len(b)-1] == '"' && bytes.IndexByte(b, '\\') < 0 && utf8.Valid(b) {
 		return string(b[len(`"`):len(b)-len(`"`)]) == s
 	}
 	// Slow-path: Unescape the string and then compare it.
@@ -104,3 +112,214 @@ func (b Literal) equalString(s string) bool {
 	var s2 string
 	return json.Unmarshal(b, &s2) == nil && s == s2
 }
+
+// appendPointerToken appends a JSON pointer reference token for name,
+// escaping it as necessary (see RFC 6901, section 3).
+func appendPointerToken(b []byte, name string) []byte {
+	b = append(b, '/')
+	for i := 0; i < len(name); i++ {
+		switch name[i] {
+		case '~':
+			b = append(b, "~0"...)
+		case '/':
+			b = append(b, "~1"...)
+		default:
+			b = append(b, name[i])
+		}
+	}
+	return b
+}
+
+// Location describes the location of a value found by FindOffset.
+type Location struct {
+	// Value is the innermost value containing the offset.
+	// If the offset lies within an object member name,
+	// then this is the ObjectMember.Name.
+	// It is nil if the offset lies outside the value entirely.
+	Value *Value
+	// Pointer is the JSON pointer (RFC 6901) to Value.
+	// For an object member name, it is the pointer to the member.
+	Pointer string
+	// Parent is the *Object or *Array that directly contains Value.
+	// It is nil for the root value.
+	Parent ValueTrimmed
+	// InComment reports whether the offset lies within a comment
+	// in the whitespace and comments surrounding or within Value.
+	InComment bool
+}
+
+// FindOffset locates the innermost value containing the byte offset n
+// within the input that v was parsed from.
+// The whitespace and comments before and after a value
+// (i.e., BeforeExtra and AfterExtra) are considered part of that value.
+// Since a comment after a comma is stored in the BeforeExtra of the next
+// object member name or array element, it is attributed to that member or
+// element (unlike the association of comments used by Patch).
+//
+// It relies on the StartOffset and EndOffset of each value,
+// so the result is only meaningful if v has not been modified since parsing.
+func (v *Value) FindOffset(n int) Location {
+	if !v.containsOffset(n) {
+		return Location{}
+	}
+	var ptr []byte
+	var parent ValueTrimmed
+	for {
+		var next *Value
+		switch comp := v.Value.(type) {
+		case *Object:
+			for i := range comp.Members {
+				m := &comp.Members[i]
+				if m.Name.containsOffset(n) || m.Value.containsOffset(n) {
+					ptr = appendPointerToken(ptr, m.Name.Value.(Literal).String())
+					next = &m.Value
+					if m.Name.containsOffset(n) {
+						next = &m.Name
+					}
+					break
+				}
+			}
+		case *Array:
+			for i := range comp.Elements {
+				if comp.Elements[i].containsOffset(n) {
+					ptr = appendPointerIndex(ptr, i)
+					next = &comp.Elements[i]
+					break
+				}
+			}
+		}
+		if next == nil {
+			break
+		}
+		v, parent = next, v.Value
+	}
+	return Location{Value: v, Pointer: string(ptr), Parent: parent, InComment: v.commentAtOffset(n)}
+}
+
+// containsOffset reports whether n lies within the value or
+// its surrounding whitespace and comments.
+func (v *Value) containsOffset(n int) bool {
+	return v.StartOffset-len(v.BeforeExtra) <= n && n < v.EndOffset+len(v.AfterExtra)
+}
+
+// commentAtOffset reports whether n lies within a comment
+// in BeforeExtra, AfterExtra, or the AfterExtra of a composite value
+// (i.e., the extra before the closing brace or bracket).
+func (v *Value) commentAtOffset(n int) bool {
+	switch {
+	case n < v.StartOffset:
+		return v.BeforeExtra.hasCommentAt(n - (v.StartOffset - len(v.BeforeExtra)))
+	case n >= v.EndOffset:
+		return v.AfterExtra.hasCommentAt(n - v.EndOffset)
+	}
+	if comp, ok := v.Value.(composite); ok {
+		extra := *comp.afterExtra()
+		start := v.EndOffset - len("}") - len(extra)
+		return start <= n && extra.hasCommentAt(n-start)
+	}
+	return false
+}
+
+// hasCommentAt reports whether the byte at offset n lies within a comment.
+func (b Extra) hasCommentAt(n int) bool {
+	for i := 0; i < len(b) && i <= n; {
+		i += consumeWhitespace(b[i:])
+		nc := consumeComment(b[i:])
+		if nc <= 0 {
+			return false
+		}
+		if i <= n && n < i+nc {
+			return true
+		}
+		i += nc
+	}
+	return false
+}
+
+// Position is a position within HuJSON input.
+type Position struct {
+	// Offset is the byte offset within the input.
+	Offset int64
+	// Line and Column are the 1-based line and column of Offset,
+	// where the column is counted in bytes.
+	Line, Column int
+}
+
+// DuplicateName describes an object member whose name
+// duplicates the name of an earlier member in the same object.
+type DuplicateName struct {
+	// Name is the unescaped member name.
+	Name string
+	// Pointer is the JSON pointer (RFC 6901) to the member.
+	// A JSON pointer cannot distinguish between members with the same name,
+	// so it is the same for both members and Find resolves it to the first.
+	// Invalid UTF-8 and unpaired surrogates in the name are replaced
+	// by U+FFFD, so names that differ only in those are also
+	// indistinguishable by pointer.
+	Pointer string
+	// First and Duplicate are the positions of the names of
+	// the first member and the duplicate member.
+	First, Duplicate Position
+}
+
+// DuplicateNames reports every object member within v that has the same
+// name as an earlier member in the same object, in the order that the
+// duplicates appear. A name that occurs n times is reported n-1 times,
+// each time relative to the first occurrence.
+// The JSON specification does not define the behavior for duplicate names,
+// and their presence is almost always a mistake.
+//
+// Positions are relative to the output of Pack and rely on the StartOffset
+// of each value, so the result is only meaningful if v has not been modified
+// since parsing or UpdateOffsets has been called since then.
+func (v Value) DuplicateNames() []DuplicateName {
+	return v.duplicateNames(v.Pack())
+}
+
+func (v *Value) duplicateNames(b []byte) (dups []DuplicateName) {
+	for p, v2 := range v.Walk() {
+		obj, ok := v2.Value.(*Object)
+		if !ok {
+			continue
+		}
+		first := make(map[string]int)
+		for i, m := range obj.Members {
+			name := m.Name.Value.(Literal)
+			key := name.exactKey()
+			j, ok := first[key]
+			if !ok {
+				first[key] = i
+				continue
+			}
+			dups = append(dups, DuplicateName{
+				Name:      name.String(),
+				Pointer:   string(appendPointerToken([]byte(p.Pointer), name.String())),
+				First:     Position{Offset: int64(obj.Members[j].Name.StartOffset)},
+				Duplicate: Position{Offset: int64(m.Name.StartOffset)},
+			})
+		}
+	}
+	slices.SortStableFunc(dups, func(x, y DuplicateName) int {
+		return cmp.Compare(x.Duplicate.Offset, y.Duplicate.Offset)
+	})
+
+	// Compute the line and column of each offset in a single pass over b.
+	var offsets []int64
+	for _, d := range dups {
+		offsets = append(offsets, d.First.Offset, d.Duplicate.Offset)
+	}
+	slices.Sort(offsets)
+	positions := make(map[int64]Position, len(offsets))
+	line, column, prev := 1, 1, 0
+	for _, offset := range slices.Compact(offsets) {
+		n := min(max(int(offset), 0), len(b))
+		line, column = advancePosition(line, column, b[prev:n])
+		positions[offset] = Position{Offset: offset, Line: line, Column: column}
+		prev = n
+	}
+	for i := range dups {
+		dups[i].First = positions[dups[i].First.Offset]
+		dups[i].Duplicate = positions[dups[i].Duplicate.Offset]
+	}
+	return dups
+}
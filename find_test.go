@@ -5,6 +5,8 @@
 package hujson
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -58,3 +60,114 @@ func TestFind(t *testing.T) {
 		}
 	}
 }
+
+func TestFindOffset(t *testing.T) {
+	in := `// Comment1
+{
+	"foo": [1, /* Comment2 */ 2], // Comment3
+	// Comment4
+	"bar": {"baz": null},
+	// Comment5
+}`
+	v, err := Parse([]byte(in))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	root := v.Value.(*Object)
+	foo := root.Members[0].Value.Value.(*Array)
+	bar := root.Members[1].Value.Value.(*Object)
+
+	tests := []struct {
+		at     string // find the offset of the first occurrence of this string
+		offset int    // relative offset from the start of at
+		want   Location
+	}{
+		{at: "Comment1", want: Location{Value: &v, InComment: true}},
+		{at: "{", want: Location{Value: &v}},
+		{at: `"foo"`, offset: 1, want: Location{Value: &root.Members[0].Name, Pointer: "/foo", Parent: root}},
+		{at: `: [`, want: Location{Value: &v}},
+		{at: `[1`, want: Location{Value: &root.Members[0].Value, Pointer: "/foo", Parent: root}},
+		{at: `1,`, want: Location{Value: &foo.Elements[0], Pointer: "/foo/0", Parent: foo}},
+		{at: "Comment2", want: Location{Value: &foo.Elements[1], Pointer: "/foo/1", Parent: foo, InComment: true}},
+		{at: `2]`, want: Location{Value: &foo.Elements[1], Pointer: "/foo/1", Parent: foo}},
+		{at: "Comment3", want: Location{Value: &root.Members[1].Name, Pointer: "/bar", Parent: root, InComment: true}},
+		{at: "Comment4", want: Location{Value: &root.Members[1].Name, Pointer: "/bar", Parent: root, InComment: true}},
+		{at: `null`, offset: 2, want: Location{Value: &bar.Members[0].Value, Pointer: "/bar/baz", Parent: bar}},
+		{at: "Comment5", want: Location{Value: &v, InComment: true}},
+		{at: "}", offset: len(in), want: Location{}},
+	}
+	for _, tt := range tests {
+		n := strings.Index(in, tt.at) + tt.offset
+		got := v.FindOffset(n)
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("FindOffset(%d) at %q mismatch (-want +got):\n%s", n, tt.at, diff)
+		}
+	}
+}
+
+func TestDuplicateNames(t *testing.T) {
+	in := `{
+	"a": 1,
+	"b": {"x": 0, "x": 1},
+	"a": 2,
+	"a": 3,
+}`
+	v, err := Parse([]byte(in))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	got := v.DuplicateNames()
+	want := []DuplicateName{{
+		Name:      "x",
+		Pointer:   "/b/x",
+		First:     Position{Offset: 18, Line: 3, Column: 8},
+		Duplicate: Position{Offset: 26, Line: 3, Column: 16},
+	}, {
+		Name:      "a",
+		Pointer:   "/a",
+		First:     Position{Offset: 3, Line: 2, Column: 2},
+		Duplicate: Position{Offset: 36, Line: 4, Column: 2},
+	}, {
+		Name:      "a",
+		Pointer:   "/a",
+		First:     Position{Offset: 3, Line: 2, Column: 2},
+		Duplicate: Position{Offset: 45, Line: 5, Column: 2},
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("DuplicateNames mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := ParseWithOptions([]byte(in), ParseOptions{}); err != nil {
+		t.Errorf("ParseWithOptions error: %v", err)
+	}
+	_, err = ParseWithOptions([]byte(in), ParseOptions{RejectDuplicateNames: true})
+	const wantErr = `hujson: line 3, column 16: duplicate object name "x" (first at line 3, column 8)`
+	var serr *SyntaxError
+	if !errors.As(err, &serr) || err.Error() != wantErr || serr.Offset != 26 {
+		t.Errorf("ParseWithOptions error = %v, want %v", err, wantErr)
+	}
+	if _, err := ParseWithOptions([]byte(`{"a":{"a":0}}`), ParseOptions{RejectDuplicateNames: true}); err != nil {
+		t.Errorf("ParseWithOptions error: %v", err)
+	}
+
+	// Names are compared by their exact decoding, such that unpaired
+	// surrogates and invalid UTF-8 are distinct from each other
+	// but equal to the same value escaped differently.
+	for _, tt := range []struct {
+		in   string
+		want int
+	}{
+		{`{"\ud800":1,"\udfff":2}`, 0},
+		{"{\"\xff\":1,\"\xfe\":2,\"\ufffd\":3}", 0},
+		{`{"\ud800":1,"\uD800":2}`, 1},
+		{`{"a":1,"\u0061":2,"\ud83d\ude00":3,"\ud83d\uDE00":4,"\u00e9":5,"é":6}`, 3},
+	} {
+		v, err := Parse([]byte(tt.in))
+		if err != nil {
+			t.Fatalf("Parse error: %v", err)
+		}
+		if got := len(v.DuplicateNames()); got != tt.want {
+			t.Errorf("len(DuplicateNames(%q)) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
@@ -6,6 +6,9 @@ package hujson
 
 import (
 	"bytes"
+	"math"
+	"slices"
+	"strings"
 	"unicode"
 )
 
@@ -35,6 +38,20 @@ func Minimize(b []byte) ([]byte, error) {
 	return ast.Pack(), nil
 }
 
+// Canonicalize transforms b into the canonical form of JSON per RFC 8785.
+// See Value.Canonicalize for details.
+// If an error is encountered, then b is returned as is along with the error.
+func Canonicalize(b []byte) ([]byte, error) {
+	ast, err := Parse(b)
+	if err != nil {
+		return b, err
+	}
+	if err := ast.Canonicalize(); err != nil {
+		return b, err
+	}
+	return ast.Pack(), nil
+}
+
 // Format formats b according to some opinionated heuristics for
 // how HuJSON should look. The exact output may change over time.
 // It is the equivalent of `go fmt` but for HuJSON.
@@ -44,16 +61,99 @@ func Minimize(b []byte) ([]byte, error) {
 // results in no changes.
 // If an error is encountered, then b is returned as is along with the error.
 func Format(b []byte) ([]byte, error) {
+	return FormatWithOptions(b, FormatOptions{})
+}
+
+// FormatWithOptions is like Format, but formats b according to opts.
+// If an error is encountered, then b is returned as is along with the error.
+func FormatWithOptions(b []byte, opts FormatOptions) ([]byte, error) {
 	ast, err := Parse(b)
 	if err != nil {
 		return b, err
 	}
-	ast.Format()
+	ast.FormatWithOptions(opts)
 	return ast.Pack(), nil
 }
 
 const punchCardWidth = 80
 
+// FormatOptions configures how Format formats a value.
+// The zero value formats the value in the same way as Format.
+type FormatOptions struct {
+	// Indent is the indentation used for each level of nesting.
+	// It must consist only of spaces and tabs.
+	// If empty or invalid, a single tab is used.
+	Indent string
+
+	// MaxLineWidth is the line width beyond which an object or array
+	// is expanded such that each member or element is on a separate line.
+	// If zero, a width of 80 is used. If negative, there is no limit.
+	MaxLineWidth int
+
+	// DisableAlignment disables aligning the values of
+	// consecutive object members to the same column.
+	DisableAlignment bool
+
+	// TrailingCommas controls the presence of trailing commas
+	// after the last member or element of an object or array.
+	TrailingCommas TrailingCommaPolicy
+
+	// MaxBlankLines is the maximum number of consecutive blank lines
+	// that are preserved between members, elements, and comments.
+	// If zero, at most one blank line is preserved.
+	// If negative, all blank lines are removed.
+	MaxBlankLines int
+}
+
+// TrailingCommaPolicy is a policy for emitting trailing commas.
+type TrailingCommaPolicy int
+
+const (
+	// TrailingCommaDefault emits a trailing comma after the last member
+	// or element of an expanded object or array, unless the input is
+	// standard JSON, and omits it for an object or array on a single line.
+	TrailingCommaDefault TrailingCommaPolicy = iota
+	// TrailingCommaNever never emits a trailing comma.
+	TrailingCommaNever
+	// TrailingCommaAlways always emits a trailing comma after the last
+	// member or element of an expanded object or array, even if the input
+	// is standard JSON, and omits it for an object or array on a single line.
+	// This ensures that adding a member or element only modifies one line.
+	TrailingCommaAlways
+	// TrailingCommaKeep preserves the presence or absence of
+	// a trailing comma as it was in the input.
+	TrailingCommaKeep
+)
+
+func (opts *FormatOptions) indent() string {
+	if opts.Indent == "" || strings.Trim(opts.Indent, " \t") != "" {
+		return "\t"
+	}
+	return opts.Indent
+}
+
+func (opts *FormatOptions) maxLineWidth() int {
+	switch {
+	case opts.MaxLineWidth == 0:
+		return punchCardWidth
+	case opts.MaxLineWidth < 0:
+		return math.MaxInt
+	default:
+		return opts.MaxLineWidth
+	}
+}
+
+func (opts *FormatOptions) maxBlankLines() int {
+	switch {
+	case opts.MaxBlankLines == 0:
+		return 1
+	case opts.MaxBlankLines < 0:
+		return 0
+	default:
+		return opts.MaxBlankLines
+	}
+}
+
 var (
 	newline        = []byte("\n")
 	twoNewlines    = []byte("\n\n")
@@ -71,18 +171,25 @@ var (
 // Format is idempotent such that formatting already formatted HuJSON
 // results in no changes.
 func (v *Value) Format() {
+	v.FormatWithOptions(FormatOptions{})
+}
+
+// FormatWithOptions is like Format, but formats the value according to opts.
+func (v *Value) FormatWithOptions(opts FormatOptions) {
 	// Format leading extra.
-	v.BeforeExtra.format(0, formatOptions{})
+	v.BeforeExtra.format(0, &opts, extraFormatOptions{})
 	v.BeforeExtra = v.BeforeExtra[consumeWhitespace(v.BeforeExtra):] // never has leading whitespace
 	// Format the value.
 	needExpand := make(map[composite]bool)
 	isStandard := v.IsStandard()
 	v.normalize()
-	v.expandComposites(needExpand)
-	v.formatWhitespace(0, needExpand, isStandard)
-	v.alignObjectValues()
+	v.expandComposites(needExpand, &opts)
+	v.formatWhitespace(0, needExpand, isStandard, &opts)
+	if !opts.DisableAlignment {
+		v.alignObjectValues()
+	}
 	// Format trailing extra.
-	v.AfterExtra.format(0, formatOptions{})
+	v.AfterExtra.format(0, &opts, extraFormatOptions{})
 	v.AfterExtra = append(bytes.TrimRightFunc(v.AfterExtra, unicode.IsSpace), '\n') // always has exactly one trailing newline
 
 	v.UpdateOffsets()
@@ -93,39 +200,35 @@ func (v *Value) Format() {
 //   - normalizes empty objects and arrays as simply {} or [],
 //   - normalizes whitespace between names and colons,
 //   - normalizes whitespace between values and commas.
-//
-// It always returns true to be compatible with composite.rangeValues.
-func (v *Value) normalize() bool {
-	switch v2 := v.Value.(type) {
-	case Literal:
-		// Normalize string if there are escape characters.
-		if v2.Kind() == '"' && bytes.IndexByte(v2, '\\') >= 0 {
-			v.Value = String(v2.String())
-		}
-	case composite:
-		// Cleanup for empty objects and arrays.
-		if v2.length() == 0 {
-			// If there is only whitespace, then remove the whitespace.
-			if !v2.afterExtra().hasComment() {
-				*v2.afterExtra() = nil
+func (v *Value) normalize() {
+	for v2 := range v.All() {
+		switch v3 := v2.Value.(type) {
+		case Literal:
+			// Normalize string if there are escape characters,
+			// unless doing so would lose unpaired surrogates or invalid UTF-8.
+			if v3.Kind() == '"' && bytes.IndexByte(v3, '\\') >= 0 && v3.isUnicodeValid() {
+				v2.Value = String(v3.String())
 			}
-			break
-		}
-
-		// If there is only whitespace between the name and colon,
-		// or between the value and comma, then remove the whitespace.
-		for v3 := range v2.allValues() {
-			if !v3.AfterExtra.hasComment() {
-				v3.AfterExtra = nil
+		case composite:
+			// Cleanup for empty objects and arrays.
+			if v3.length() == 0 {
+				// If there is only whitespace, then remove the whitespace.
+				if !v3.afterExtra().hasComment() {
+					*v3.afterExtra() = nil
+				}
+				break
 			}
-		}
 
-		// Normalize all sub-values.
-		for v3 := range v2.allValues() {
-			v3.normalize()
+			// If there is only whitespace between the name and colon,
+			// or between the value and comma, then remove the whitespace.
+			// The presence of a trailing comma is preserved.
+			for v4 := range v3.allValues() {
+				if !v4.AfterExtra.hasComment() {
+					v4.AfterExtra = v4.AfterExtra[:0:0]
+				}
+			}
 		}
 	}
-	return true
 }
 
 // lineStats carries statistics about a sequence of lines.
@@ -138,84 +241,91 @@ type lineStats struct {
 // expandComposites populates needExpand with the set of composite values
 // that need to be expanded (i.e., print each member/element on a new line).
 // This method is pure and does not mutate the AST.
-func (v *Value) expandComposites(needExpand map[composite]bool) (stats lineStats) {
-	switch v2 := v.Value.(type) {
-	case Literal:
-		stats = lineStats{len(v2), len(v2), false}
-	case composite:
-		// Every object or array is either fully inlined or fully expanded.
-		// This simplifies machine-modification of HuJSON so that the mutation
-		// can easily determine which mode it is currently in.
-		//
-		// If any whitespace after a '{', '[', or ',' or before a '}' or ']'
-		// contains a newline, then we always expand the object or array.
-		var expand bool
-
-		// Keep track of line lengths.
-		var lineLength int
-		var lineLengths []int
-		updateStats := func(s lineStats) {
-			lineLength += s.firstLength
-			if s.multiline {
-				lineLengths = append(lineLengths, lineLength)
-				lineLength = s.lastLength
-			}
+func (v *Value) expandComposites(needExpand map[composite]bool, opts *FormatOptions) (stats lineStats) {
+	// Every object or array is either fully inlined or fully expanded.
+	// This simplifies machine-modification of HuJSON so that the mutation
+	// can easily determine which mode it is currently in.
+	//
+	// If any whitespace after a '{', '[', or ',' or before a '}' or ']'
+	// contains a newline, then we always expand the object or array.
+	//
+	// Statistics are computed for each member and element before
+	// the object or array containing it, using an explicit stack
+	// rather than recursion so that deeply nested values
+	// do not grow the goroutine stack.
+	type frame struct {
+		comp        composite
+		next        int  // index of the next value to visit (see valueAt)
+		expand      bool // whether the object or array must be expanded
+		lineLength  int  // length of the current line
+		lineLengths []int
+	}
+	updateStats := func(f *frame, s lineStats) {
+		f.lineLength += s.firstLength
+		if s.multiline {
+			f.lineLengths = append(f.lineLengths, f.lineLength)
+			f.lineLength = s.lastLength
+		}
+	}
+	var stack []frame
+	visit := func(v *Value) {
+		switch v2 := v.Value.(type) {
+		case Literal:
+			stats = lineStats{len(v2), len(v2), false}
+		case composite:
+			stack = append(stack, frame{comp: v2, lineLength: len("{")})
 		}
+	}
 
-		// Iterate through all members/elements in an object/array.
-		switch v2 := v2.(type) {
-		case *Object:
-			lineLength += len("{")
-			for i := range v2.Members {
-				name := &v2.Members[i].Name
-				value := &v2.Members[i].Value
-				expand = expand || name.BeforeExtra.hasNewline()
-				updateStats(name.BeforeExtra.lineStats())
-				updateStats(name.expandComposites(needExpand))
-				updateStats(name.AfterExtra.lineStats())
-				lineLength += len(": ")
-				updateStats(value.BeforeExtra.lineStats())
-				updateStats(value.expandComposites(needExpand))
-				updateStats(value.AfterExtra.lineStats())
-				lineLength += len(", ")
+	visit(v)
+	for len(stack) > 0 {
+		f := &stack[len(stack)-1]
+		_, isObject := f.comp.(*Object)
+
+		// Account for the statistics of the previously visited value.
+		if i := f.next - 1; i >= 0 {
+			updateStats(f, stats)
+			updateStats(f, valueAt(f.comp, i).AfterExtra.lineStats())
+			if isObject && i%2 == 0 {
+				f.lineLength += len(": ")
+			} else {
+				f.lineLength += len(", ")
 			}
-			lineLength += len("}")
+		}
 
-			// Always expand multiline objects with more than 1 member.
-			expand = expand || v2.length() > 1 && stats.multiline
-		case *Array:
-			lineLength += len("[")
-			for i := range v2.Elements {
-				value := &v2.Elements[i]
-				expand = expand || value.BeforeExtra.hasNewline()
-				updateStats(value.BeforeExtra.lineStats())
-				updateStats(value.expandComposites(needExpand))
-				updateStats(value.AfterExtra.lineStats())
-				lineLength += len(", ")
+		// Visit the next object name or value, or array element.
+		if i := f.next; i < numValues(f.comp) {
+			v2 := valueAt(f.comp, i)
+			f.next++
+			if !isObject || i%2 == 0 {
+				f.expand = f.expand || v2.BeforeExtra.hasNewline()
 			}
-			lineLength += len("]")
-		}
-		if last := v2.lastValue(); last != nil {
-			expand = expand || last.AfterExtra.hasNewline()
+			updateStats(f, v2.BeforeExtra.lineStats())
+			visit(v2)
+			continue
 		}
-		expand = expand || v2.afterExtra().hasNewline()
 
-		// Update the block statistics.
-		lineLengths = append(lineLengths, lineLength)
+		// Compute the statistics for the object or array.
+		f.lineLength += len("}")
+		if last := f.comp.lastValue(); last != nil {
+			f.expand = f.expand || last.AfterExtra.hasNewline()
+		}
+		f.expand = f.expand || f.comp.afterExtra().hasNewline()
+		f.lineLengths = append(f.lineLengths, f.lineLength)
 		stats = lineStats{
-			firstLength: lineLengths[0],
-			lastLength:  lineLengths[len(lineLengths)-1],
-			multiline:   len(lineLengths) > 1,
+			firstLength: f.lineLengths[0],
+			lastLength:  f.lineLengths[len(f.lineLengths)-1],
+			multiline:   len(f.lineLengths) > 1,
 		}
-		for i := 0; !expand && i < len(lineLengths); i++ {
-			expand = lineLengths[i] > punchCardWidth
+		for i := 0; !f.expand && i < len(f.lineLengths); i++ {
+			f.expand = f.lineLengths[i] > opts.maxLineWidth()
 		}
-
-		if expand {
+		if f.expand {
 			stats = lineStats{len("{"), len("}"), true}
-			stats.firstLength += v2.beforeExtraAt(0).lineStats().firstLength
-			needExpand[v2] = expand
+			stats.firstLength += f.comp.beforeExtraAt(0).lineStats().firstLength
+			needExpand[f.comp] = true
 		}
+		stack = stack[:len(stack)-1]
 	}
 	return stats
 }
@@ -256,8 +366,23 @@ func (b Extra) lineStats() (stats lineStats) {
 
 // formatWhitespace mutates the AST and formats whitespace to ensure
 // consistent indentation and expansion of objects and arrays.
-func (v *Value) formatWhitespace(depth int, needExpand map[composite]bool, standardize bool) {
-	if comp, ok := v.Value.(composite); ok {
+//
+// Each object or array is formatted independently of its descendants,
+// so the values are processed using an explicit stack rather than recursion
+// such that deeply nested values do not grow the goroutine stack.
+func (v *Value) formatWhitespace(depth int, needExpand map[composite]bool, standardize bool, opts *FormatOptions) {
+	type formatEntry struct {
+		v     *Value
+		depth int
+	}
+	stack := []formatEntry{{v, depth}}
+	for len(stack) > 0 {
+		v, depth := stack[len(stack)-1].v, stack[len(stack)-1].depth
+		stack = stack[:len(stack)-1]
+		comp, ok := v.Value.(composite)
+		if !ok {
+			continue
+		}
 		expand := needExpand[comp]
 
 		// Format all members/elements in an object/array.
@@ -268,20 +393,20 @@ func (v *Value) formatWhitespace(depth int, needExpand map[composite]bool, stand
 				value := &comp.Members[i].Value
 
 				// Format extra before name.
-				name.BeforeExtra.format(depth+1, formatOptions{
+				name.BeforeExtra.format(depth+1, opts, extraFormatOptions{
 					ensureLeadingNewline:    expand,
 					removeLeadingEmptyLines: i == 0,
 					appendSpaceIfEmpty:      i != 0,
 				})
 				// Format the name.
-				name.formatWhitespace(depth+1, needExpand, standardize)
+				stack = append(stack, formatEntry{name, depth + 1})
 				// Format extra after name and before colon.
-				name.AfterExtra.format(depth+2, formatOptions{
+				name.AfterExtra.format(depth+2, opts, extraFormatOptions{
 					removeLeadingEmptyLines:  true,
 					removeTrailingEmptyLines: true,
 				})
 				// Format extra after colon and before value.
-				value.BeforeExtra.format(depth+2, formatOptions{
+				value.BeforeExtra.format(depth+2, opts, extraFormatOptions{
 					removeLeadingEmptyLines:  true,
 					removeTrailingEmptyLines: true,
 					appendSpaceIfEmpty:       true,
@@ -294,9 +419,9 @@ func (v *Value) formatWhitespace(depth int, needExpand map[composite]bool, stand
 				if name.AfterExtra.hasNewline() || value.BeforeExtra.hasNewline() {
 					depthOffset++
 				}
-				value.formatWhitespace(depth+depthOffset, needExpand, standardize)
+				stack = append(stack, formatEntry{value, depth + depthOffset})
 				// Format extra after value and before comma.
-				value.AfterExtra.format(depth+2, formatOptions{
+				value.AfterExtra.format(depth+2, opts, extraFormatOptions{
 					removeLeadingEmptyLines:  true,
 					removeTrailingEmptyLines: true,
 				})
@@ -306,7 +431,7 @@ func (v *Value) formatWhitespace(depth int, needExpand map[composite]bool, stand
 				value := &comp.Elements[i]
 
 				// Format extra before value.
-				value.BeforeExtra.format(depth+1, formatOptions{
+				value.BeforeExtra.format(depth+1, opts, extraFormatOptions{
 					ensureLeadingNewline:    expand,
 					removeLeadingEmptyLines: i == 0,
 					appendSpaceIfEmpty:      i != 0,
@@ -316,9 +441,9 @@ func (v *Value) formatWhitespace(depth int, needExpand map[composite]bool, stand
 				if expand {
 					depthOffset++
 				}
-				value.formatWhitespace(depth+depthOffset, needExpand, standardize)
+				stack = append(stack, formatEntry{value, depth + depthOffset})
 				// Format extra after value and before comma.
-				value.AfterExtra.format(depth+2, formatOptions{
+				value.AfterExtra.format(depth+2, opts, extraFormatOptions{
 					removeLeadingEmptyLines:  true,
 					removeTrailingEmptyLines: true,
 				})
@@ -326,7 +451,7 @@ func (v *Value) formatWhitespace(depth int, needExpand map[composite]bool, stand
 		}
 
 		// Format the extra before the closing '}' or ']'.
-		comp.afterExtra().format(depth+1, formatOptions{
+		comp.afterExtra().format(depth+1, opts, extraFormatOptions{
 			ensureTrailingNewline:    expand,
 			removeLeadingEmptyLines:  comp.length() == 0,
 			removeTrailingEmptyLines: true,
@@ -336,17 +461,22 @@ func (v *Value) formatWhitespace(depth int, needExpand map[composite]bool, stand
 		// Normalize presence of trailing comma.
 		surroundedComma := comp.lastValue() != nil && len(comp.lastValue().AfterExtra) > 0 && len(*comp.afterExtra()) > 0
 		switch {
+		// Preserve the trailing comma if the policy requires it.
+		case opts.TrailingCommas == TrailingCommaKeep:
+		// Avoid a trailing comma if the policy forbids it.
+		case opts.TrailingCommas == TrailingCommaNever:
+			setTrailingComma(comp, false)
 		// Avoid a trailing comma for a non-expanded object or array.
 		case !expand && !surroundedComma:
 			setTrailingComma(comp, false)
 		// Otherwise, emit a trailing comma (unless this need to be standard).
-		case expand && !standardize:
+		case expand && (!standardize || opts.TrailingCommas == TrailingCommaAlways):
 			setTrailingComma(comp, true)
 		}
 	}
 }
 
-type formatOptions struct {
+type extraFormatOptions struct {
 	ensureLeadingNewline     bool
 	ensureTrailingNewline    bool
 	removeLeadingEmptyLines  bool
@@ -355,7 +485,7 @@ type formatOptions struct {
 	appendSpaceIfEmpty       bool
 }
 
-func (b *Extra) format(depth int, opts formatOptions) {
+func (b *Extra) format(depth int, fopts *FormatOptions, opts extraFormatOptions) {
 	// Remove carriage returns to normalize output across operating systems.
 	if bytes.IndexByte(*b, '\r') >= 0 {
 		*b = bytes.ReplaceAll(*b, endlineWindows, newline)
@@ -376,8 +506,8 @@ func (b *Extra) format(depth int, opts formatOptions) {
 		// Handle whitespace.
 		if n := consumeWhitespace(in); n > 0 {
 			nl := bytes.Count(in[:n], newline)
-			if nl > 2 {
-				nl = 2 // never allow more than one blank line
+			if maxNL := 1 + fopts.maxBlankLines(); nl > maxNL {
+				nl = maxNL // limit the number of blank lines
 			}
 			for i := 0; i < nl; i++ {
 				out = append(out, '\n')
@@ -394,7 +524,7 @@ func (b *Extra) format(depth int, opts formatOptions) {
 
 		// Emit leading whitespace.
 		if bytes.HasSuffix(out, newline) {
-			out = appendIndent(out, depth)
+			out = appendIndent(out, fopts.indent(), depth)
 		} else {
 			out = append(out, ' ')
 		}
@@ -457,7 +587,7 @@ func (b *Extra) format(depth int, opts formatOptions) {
 		out = append(out, '\n')
 		for _, line := range lines[1:] {
 			if len(line) > 0 {
-				out = appendIndent(out, depth)
+				out = appendIndent(out, fopts.indent(), depth)
 				if starAligned {
 					out = append(out, ' ')
 				}
@@ -487,7 +617,7 @@ func (b *Extra) format(depth int, opts formatOptions) {
 		if opts.unindentLastLine {
 			depth--
 		}
-		out = appendIndent(out, depth)
+		out = appendIndent(out, fopts.indent(), depth)
 	} else if len(out) > 0 {
 		out = append(out, ' ')
 	}
@@ -504,13 +634,35 @@ func (b *Extra) format(depth int, opts formatOptions) {
 
 // alignObjectValues aligns object values by inserting spaces after the name
 // so that the values are aligned to the same column.
-//
-// It always returns true to be compatible with composite.rangeValues.
-func (v *Value) alignObjectValues() bool {
+func (v *Value) alignObjectValues() {
 	// TODO(dsnet): This is broken for non-monospace, non-narrow characters.
 	// This is hard to fix as even `go fmt` suffers from this problem.
 	// See https://golang.org/issue/8273.
-	if obj, ok := v.Value.(*Object); ok {
+
+	// Determine which values contain a newline (excluding the extra
+	// immediately before and after the value) in reverse order,
+	// such that members and elements are handled before their parent.
+	var values []*Value
+	for v2 := range v.All() {
+		values = append(values, v2)
+	}
+	hasNewline := make(map[*Value]bool)
+	for _, v2 := range slices.Backward(values) {
+		if comp, ok := v2.Value.(composite); ok {
+			for v3 := range comp.allValues() {
+				if v3.BeforeExtra.hasNewline() || v3.AfterExtra.hasNewline() || hasNewline[v3] {
+					hasNewline[v2] = true
+					break
+				}
+			}
+		}
+	}
+
+	for _, v2 := range values {
+		obj, ok := v2.Value.(*Object)
+		if !ok {
+			continue
+		}
 		type row struct {
 			extra  *Extra // pointer to extra after colon and before value
 			length int    // length from start of name to end of extra
@@ -544,10 +696,10 @@ func (v *Value) alignObjectValues() bool {
 			// Whitespace right before name must have a newline and
 			// everything after the name until the comma cannot have newlines.
 			if !name.BeforeExtra.hasNewline() ||
-				name.hasNewline(false) ||
+				hasNewline[name] ||
 				name.AfterExtra.hasNewline() ||
 				value.BeforeExtra.hasNewline() ||
-				value.hasNewline(false) ||
+				hasNewline[value] ||
 				value.AfterExtra.hasNewline() {
 				alignRows()
 				continue
@@ -566,37 +718,15 @@ func (v *Value) alignObjectValues() bool {
 		}
 		alignRows()
 	}
-
-	// Recursively align all sub-objects.
-	if comp, ok := v.Value.(composite); ok {
-		for v2 := range comp.allValues() {
-			v2.alignObjectValues()
-		}
-	}
-	return true
-}
-
-func (v Value) hasNewline(checkTopLevelExtra bool) bool {
-	if checkTopLevelExtra && (v.BeforeExtra.hasNewline() || v.AfterExtra.hasNewline()) {
-		return true
-	}
-	if comp, ok := v.Value.(composite); ok {
-		for v := range comp.allValues() {
-			if v.hasNewline(true) {
-				return true
-			}
-		}
-	}
-	return false
 }
 
 func (b Extra) hasNewline() bool {
 	return bytes.IndexByte(b, '\n') >= 0
 }
 
-func appendIndent(b []byte, n int) []byte {
+func appendIndent(b []byte, indent string, n int) []byte {
 	for i := 0; i < n; i++ {
-		b = append(b, '\t')
+		b = append(b, indent...)
 	}
 	return b
 }
@@ -5,6 +5,7 @@
 package hujson
 
 import (
+	"errors"
 	"strings"
 	"testing"
 
@@ -18,14 +19,18 @@ func TestFormatErrors(t *testing.T) {
 	}{
 		{"Standardize", Standardize},
 		{"Minimize", Minimize},
+		{"Canonicalize", Canonicalize},
 		{"Format", Format},
 	}
 
 	const want = "[null,false,true,invalid]"
 	for _, tt := range tests {
 		got, err := tt.format([]byte(want))
-		if err == nil {
-			t.Errorf("%s error = nil, want non-nil", tt.name)
+		var serr *SyntaxError
+		if !errors.As(err, &serr) {
+			t.Errorf("%s error = %v, want *SyntaxError", tt.name, err)
+		} else if serr.Offset != 17 || serr.Char != 'i' {
+			t.Errorf("%s error at offset %d with %q, want offset 17 with 'i'", tt.name, serr.Offset, serr.Char)
 		}
 		if string(got) != want {
 			t.Errorf("%s = %q, want %q", tt.name, got, want)
@@ -33,6 +38,60 @@ func TestFormatErrors(t *testing.T) {
 	}
 }
 
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr string
+	}{{
+		// Example from RFC 8785, section 3.2.2.
+		in: `{
+			"numbers": [333333333.33333329, 1E30, 4.50,
+						2e-3, 0.000000000000000000000000001],
+			"string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+			"literals": [null, true, false],
+		}`,
+		want: `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+	}, {
+		// Example from RFC 8785, section 3.2.3.
+		in:   `{"\u20ac": "Euro Sign", "\r": "Carriage Return", "\ufb33": "Hebrew Letter Dalet With Dagesh", "1": "One", "\ud83d\ude00": "Emoji: Grinning Face", "\u0080": "Control", "\u00f6": "Latin Small Letter O With Diaeresis"}`,
+		want: "{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"ö\":\"Latin Small Letter O With Diaeresis\",\"€\":\"Euro Sign\",\"😀\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}",
+	}, {
+		in:   `/* comment */ [-0, -0.0, 1e21, 1e20, 1e-7, 0.000001, 123456789012345678901234567890] /* comment */`,
+		want: `[0,0,1e+21,100000000000000000000,1e-7,0.000001,1.2345678901234568e+29]`,
+	}, {
+		in:      `{"a": {"b": 1, "b": 2}}`,
+		wantErr: `hujson: cannot canonicalize object at "/a": duplicate name "b"`,
+	}, {
+		in:      `[0, "\ud800"]`,
+		wantErr: `hujson: cannot canonicalize value at "/1": invalid Unicode in string "\ud800"`,
+	}, {
+		in:      `{"\udc00\ud800": 0}`,
+		wantErr: `hujson: cannot canonicalize object name at "": invalid Unicode in string "\udc00\ud800"`,
+	}, {
+		in:      `{"a": [1e400]}`,
+		wantErr: `hujson: cannot canonicalize value at "/a/0": number 1e400 is not representable as an IEEE 754 double`,
+	}}
+	for _, tt := range tests {
+		got, err := Canonicalize([]byte(tt.in))
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Canonicalize(%s) error = %v, want %v", tt.in, err, tt.wantErr)
+			}
+			if string(got) != tt.in {
+				t.Errorf("Canonicalize(%s) = %s, want input unchanged", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Canonicalize(%s) error: %v", tt.in, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("Canonicalize(%s):\ngot  %s\nwant %s", tt.in, got, tt.want)
+		}
+	}
+}
+
 var testdataFormat = []struct {
 	in   string
 	want string
@@ -48,6 +107,9 @@ var testdataFormat = []struct {
 }, {
 	in:   `"\u000F\u000a\/\ud83d\ude02"`,
 	want: `"\u000f\n/😂"`,
+}, {
+	in:   `["\ud800\u0041", "\udc00"]`,
+	want: `["\ud800\u0041", "\udc00"]`,
 }, {
 	in:   "{\n\r\t \n\r\t }",
 	want: "{}",
@@ -533,3 +595,184 @@ func TestFormat(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatWithOptions(t *testing.T) {
+	// The zero value must be identical to Format.
+	for _, tt := range testdataFormat {
+		want, _ := Format([]byte(tt.in))
+		got, _ := FormatWithOptions([]byte(tt.in), FormatOptions{})
+		if diff := cmp.Diff(string(want), string(got)); diff != "" {
+			t.Errorf("FormatWithOptions(%q, FormatOptions{}) mismatch (-Format +FormatWithOptions):\n%s", tt.in, diff)
+		}
+	}
+
+	const in = `{
+	"name": "value",
+	"longerName": [1, 2, 3],
+
+
+	// Comment
+	"object": {"key": "value"},
+}`
+	tests := []struct {
+		opts FormatOptions
+		want string
+	}{{
+		opts: FormatOptions{Indent: "  "},
+		want: `
+{
+  "name":       "value",
+  "longerName": [1, 2, 3],
+
+  // Comment
+  "object": {"key": "value"},
+}`,
+	}, {
+		opts: FormatOptions{Indent: "invalid"},
+		want: `
+{
+	"name":       "value",
+	"longerName": [1, 2, 3],
+
+	// Comment
+	"object": {"key": "value"},
+}`,
+	}, {
+		opts: FormatOptions{MaxLineWidth: 16},
+		want: `
+{
+	"name":       "value",
+	"longerName": [1, 2, 3],
+
+	// Comment
+	"object": {
+		"key": "value",
+	},
+}`,
+	}, {
+		opts: FormatOptions{DisableAlignment: true, MaxBlankLines: 2},
+		want: `
+{
+	"name": "value",
+	"longerName": [1, 2, 3],
+
+
+	// Comment
+	"object": {"key": "value"},
+}`,
+	}, {
+		opts: FormatOptions{TrailingCommas: TrailingCommaNever, MaxBlankLines: -1},
+		want: `
+{
+	"name":       "value",
+	"longerName": [1, 2, 3],
+	// Comment
+	"object": {"key": "value"}
+}`,
+	}}
+	for _, tt := range tests {
+		got, err := FormatWithOptions([]byte(in), tt.opts)
+		if err != nil {
+			t.Fatalf("FormatWithOptions error: %v", err)
+		}
+		want := strings.TrimPrefix(tt.want, "\n") + "\n"
+		if diff := cmp.Diff(want, string(got)); diff != "" {
+			t.Errorf("FormatWithOptions(%+v) mismatch (-want +got):\n%s", tt.opts, diff)
+		}
+		// Formatting must be idempotent.
+		got2, _ := FormatWithOptions(got, tt.opts)
+		if diff := cmp.Diff(string(got), string(got2)); diff != "" {
+			t.Errorf("FormatWithOptions(%+v) not idempotent (-first +second):\n%s", tt.opts, diff)
+		}
+	}
+}
+
+func TestFormatTrailingCommas(t *testing.T) {
+	const in = `{
+	"standard": {
+		"key": "value"
+	},
+	"inlined": [1, 2, 3,],
+	"expanded": [
+		1,
+		2,
+	],
+}`
+	tests := []struct {
+		policy TrailingCommaPolicy
+		want   string
+	}{{
+		policy: TrailingCommaDefault,
+		want: `
+{
+	"standard": {
+		"key": "value",
+	},
+	"inlined": [1, 2, 3],
+	"expanded": [
+		1,
+		2,
+	],
+}`,
+	}, {
+		policy: TrailingCommaNever,
+		want: `
+{
+	"standard": {
+		"key": "value"
+	},
+	"inlined": [1, 2, 3],
+	"expanded": [
+		1,
+		2
+	]
+}`,
+	}, {
+		policy: TrailingCommaAlways,
+		want: `
+{
+	"standard": {
+		"key": "value",
+	},
+	"inlined": [1, 2, 3],
+	"expanded": [
+		1,
+		2,
+	],
+}`,
+	}, {
+		policy: TrailingCommaKeep,
+		want: `
+{
+	"standard": {
+		"key": "value"
+	},
+	"inlined": [1, 2, 3,],
+	"expanded": [
+		1,
+		2,
+	],
+}`,
+	}}
+	for _, tt := range tests {
+		got, err := FormatWithOptions([]byte(in), FormatOptions{TrailingCommas: tt.policy})
+		if err != nil {
+			t.Fatalf("FormatWithOptions error: %v", err)
+		}
+		want := strings.TrimPrefix(tt.want, "\n") + "\n"
+		if diff := cmp.Diff(want, string(got)); diff != "" {
+			t.Errorf("FormatWithOptions(TrailingCommas: %v) mismatch (-want +got):\n%s", tt.policy, diff)
+		}
+	}
+
+	// Standard JSON remains standard unless trailing commas are always emitted.
+	const std = "{\n\t\"key\": \"value\"\n}\n"
+	for _, policy := range []TrailingCommaPolicy{TrailingCommaDefault, TrailingCommaNever, TrailingCommaKeep} {
+		if got, _ := FormatWithOptions([]byte(std), FormatOptions{TrailingCommas: policy}); string(got) != std {
+			t.Errorf("FormatWithOptions(TrailingCommas: %v) = %q, want %q", policy, got, std)
+		}
+	}
+	if got, _ := FormatWithOptions([]byte(std), FormatOptions{TrailingCommas: TrailingCommaAlways}); string(got) != "{\n\t\"key\": \"value\",\n}\n" {
+		t.Errorf("FormatWithOptions(TrailingCommas: TrailingCommaAlways) = %q, want trailing comma", got)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/google/go-cmp/cmp"
 )
@@ -86,3 +87,63 @@ func FuzzPatch(f *testing.F) {
 		v.Patch(patch)
 	})
 }
+
+func FuzzParseWithRecovery(f *testing.F) {
+	for _, tt := range testdata {
+		f.Add([]byte(tt.in))
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		if len(b) > 1<<12 {
+			t.Skip("input too large")
+		}
+
+		// Recovery must always terminate and agree with Parse.
+		v, errs := ParseWithRecovery(b)
+		_, err := Parse(b)
+		if (err == nil) != (len(errs) == 0) {
+			t.Fatalf("input %q: Parse error = %v, ParseWithRecovery errors = %v", b, err, errs)
+		}
+		if err == nil && !bytes.Equal(b, v.Pack()) {
+			t.Fatalf("input %q: Pack mismatch: %s", b, cmp.Diff(b, v.Pack()))
+		}
+	})
+}
+
+func FuzzDiff(f *testing.F) {
+	for _, tt := range testdataDiff {
+		f.Add([]byte(tt.from), []byte(tt.to))
+	}
+	f.Fuzz(func(t *testing.T, from, to []byte) {
+		if len(from) > 1<<12 || len(to) > 1<<12 {
+			t.Skip("inputs too large")
+		}
+
+		// Parse for valid HuJSON inputs.
+		vFrom, err := Parse(from)
+		if err != nil {
+			t.Skipf("input %q: Parse error: %v", from, err)
+		}
+		vTo, err := Parse(to)
+		if err != nil {
+			t.Skipf("input %q: Parse error: %v", to, err)
+		}
+		if !utf8.Valid(from) || !utf8.Valid(to) {
+			t.Skip("invalid UTF-8 cannot be referenced by a JSON pointer")
+		}
+		if !equalValue(vFrom, vFrom) || !equalValue(vTo, vTo) {
+			t.Skip("inputs not comparable")
+		}
+
+		// Applying the diff should produce a semantically equal value.
+		patch, err := Diff(vFrom, vTo)
+		if err != nil {
+			t.Fatalf("inputs %q, %q: Diff error: %v", from, to, err)
+		}
+		if err := vFrom.Patch(patch); err != nil {
+			t.Fatalf("inputs %q, %q: Patch error: %v\npatch: %s", from, to, err, patch)
+		}
+		if !equalValue(vFrom, vTo) {
+			t.Fatalf("inputs %q, %q: Patch result mismatch:\ngot  %s\nwant %s\npatch: %s", from, to, vFrom, vTo, patch)
+		}
+	})
+}
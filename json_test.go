@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -41,7 +42,7 @@ var testdata = []struct {
 		Value:       Literal("null"),
 		EndOffset:   5,
 	},
-	wantErr: fmt.Errorf("hujson: line 1, column 6: %w", errors.New("invalid character ',' after top-level value")),
+	wantErr: &SyntaxError{Offset: 5, Line: 1, Column: 6, Char: ',', Expected: "end of input", Err: errors.New("invalid character ',' after top-level value")},
 }, {
 	in: "//😊 \r\t\n/*\r\t\n*/null//😊 \r\t\n/*\r\t\n*/",
 	want: Value{
@@ -55,17 +56,17 @@ var testdata = []struct {
 	wantStd: "       \r\t\n  \r\t\n  null       \r\t\n  \r\t\n  ",
 }, {
 	in:      "/?",
-	wantErr: fmt.Errorf("hujson: line 1, column 1: %w", errors.New("invalid character '/' at start of value")),
+	wantErr: &SyntaxError{Offset: 0, Line: 1, Column: 1, Char: '/', Expected: "value", Err: errors.New("invalid character '/' at start of value")},
 }, {
 	in:      "//\xde\xad\xbe\xef\nnull",
-	wantErr: fmt.Errorf("hujson: line 1, column 1: %w", errors.New("invalid UTF-8 in comment")),
+	wantErr: &SyntaxError{Offset: 0, Line: 1, Column: 1, Char: '/', Expected: "valid UTF-8 in comment", Err: errors.New("invalid UTF-8 in comment")},
 }, {
 	in: "null//",
 	want: Value{
 		Value:     Literal("null"),
 		EndOffset: 4,
 	},
-	wantErr: fmt.Errorf("hujson: line 1, column 5: %w", fmt.Errorf("parsing comment: %w", io.ErrUnexpectedEOF)),
+	wantErr: &SyntaxError{Offset: 4, Line: 1, Column: 5, Char: '/', Expected: "end of comment", Err: fmt.Errorf("parsing comment: %w", io.ErrUnexpectedEOF)},
 }, {
 	in: "null//\n",
 	want: Value{
@@ -77,10 +78,10 @@ var testdata = []struct {
 	wantStd: "null  \n",
 }, {
 	in:      `"\"\\\u0022😊`,
-	wantErr: fmt.Errorf("hujson: line 1, column 16: %w", fmt.Errorf("parsing string: %w", io.ErrUnexpectedEOF)),
+	wantErr: &SyntaxError{Offset: 15, Line: 1, Column: 16, Expected: "end of string", Err: fmt.Errorf("parsing string: %w", io.ErrUnexpectedEOF)},
 }, {
 	in:      `"\xff"`,
-	wantErr: fmt.Errorf("hujson: line 1, column 1: %w", errors.New("invalid literal: \"\\xff\"")),
+	wantErr: &SyntaxError{Offset: 0, Line: 1, Column: 1, Char: '"', Expected: "valid string", Err: errors.New("invalid literal: \"\\xff\"")},
 }, {
 	in:      `"\"\\\u0022😊"`,
 	want:    Value{Value: Literal(`"\"\\\u0022😊"`), EndOffset: 16},
@@ -93,31 +94,31 @@ var testdata = []struct {
 	wantStd: `3.14159E+435`,
 }, {
 	in:      `+1000`,
-	wantErr: fmt.Errorf("hujson: line 1, column 1: %w", errors.New("invalid literal: +1000")),
+	wantErr: &SyntaxError{Offset: 0, Line: 1, Column: 1, Char: '+', Expected: "null, boolean, or number", Err: errors.New("invalid literal: +1000")},
 }, {
 	in:      "{",
 	want:    Value{Value: &Object{}},
-	wantErr: fmt.Errorf("hujson: line 1, column 2: %w", fmt.Errorf("parsing value: %w", io.ErrUnexpectedEOF)),
+	wantErr: &SyntaxError{Offset: 1, Line: 1, Column: 2, Expected: "value", Err: fmt.Errorf("parsing value: %w", io.ErrUnexpectedEOF)},
 }, {
 	in:      "{,}",
 	want:    Value{Value: &Object{}},
-	wantErr: fmt.Errorf("hujson: line 1, column 2: %w", errors.New("invalid character ',' at start of value")),
+	wantErr: &SyntaxError{Offset: 1, Line: 1, Column: 2, Char: ',', Expected: "value", Err: errors.New("invalid character ',' at start of value")},
 }, {
 	in:      `{null:"v"`,
 	want:    Value{Value: &Object{}},
-	wantErr: fmt.Errorf("hujson: line 1, column 2: %w", errors.New("invalid character 'n' at start of object name")),
+	wantErr: &SyntaxError{Offset: 1, Line: 1, Column: 2, Char: 'n', Expected: "object name", Err: errors.New("invalid character 'n' at start of object name")},
 }, {
 	in:      `{"k"`,
 	want:    Value{Value: &Object{}},
-	wantErr: fmt.Errorf("hujson: line 1, column 5: %w", fmt.Errorf("parsing object after name: %w", io.ErrUnexpectedEOF)),
+	wantErr: &SyntaxError{Offset: 4, Line: 1, Column: 5, Expected: "':'", Err: fmt.Errorf("parsing object after name: %w", io.ErrUnexpectedEOF)},
 }, {
 	in:      `{"k";`,
 	want:    Value{Value: &Object{}},
-	wantErr: fmt.Errorf("hujson: line 1, column 5: %w", errors.New("invalid character ';' after object name")),
+	wantErr: &SyntaxError{Offset: 4, Line: 1, Column: 5, Char: ';', Expected: "':'", Err: errors.New("invalid character ';' after object name")},
 }, {
 	in:      `{"k":}`,
 	want:    Value{Value: &Object{}},
-	wantErr: fmt.Errorf("hujson: line 1, column 6: %w", errors.New("invalid character '}' at start of value")),
+	wantErr: &SyntaxError{Offset: 5, Line: 1, Column: 6, Char: '}', Expected: "value", Err: errors.New("invalid character '}' at start of value")},
 }, {
 	in: `{"k":"v"`,
 	want: Value{Value: &Object{
@@ -126,7 +127,7 @@ var testdata = []struct {
 			Value{StartOffset: 5, Value: Literal(`"v"`), EndOffset: 8},
 		}},
 	}},
-	wantErr: fmt.Errorf("hujson: line 1, column 9: %w", fmt.Errorf("parsing object after value: %w", io.ErrUnexpectedEOF)),
+	wantErr: &SyntaxError{Offset: 8, Line: 1, Column: 9, Expected: "',' or '}'", Err: fmt.Errorf("parsing object after value: %w", io.ErrUnexpectedEOF)},
 }, {
 	in: `{"k":"v"]`,
 	want: Value{Value: &Object{
@@ -135,7 +136,7 @@ var testdata = []struct {
 			Value{StartOffset: 5, Value: Literal(`"v"`), EndOffset: 8},
 		}},
 	}},
-	wantErr: fmt.Errorf("hujson: line 1, column 9: %w", errors.New("invalid character ']' after object value (expecting ',' or '}')")),
+	wantErr: &SyntaxError{Offset: 8, Line: 1, Column: 9, Char: ']', Expected: "',' or '}'", Err: errors.New("invalid character ']' after object value (expecting ',' or '}')")},
 }, {
 	in: ` { "k" : "v" } `,
 	want: Value{
@@ -173,23 +174,23 @@ var testdata = []struct {
 }, {
 	in:      "[",
 	want:    Value{Value: &Array{}},
-	wantErr: fmt.Errorf("hujson: line 1, column 2: %w", fmt.Errorf("parsing value: %w", io.ErrUnexpectedEOF)),
+	wantErr: &SyntaxError{Offset: 1, Line: 1, Column: 2, Expected: "value", Err: fmt.Errorf("parsing value: %w", io.ErrUnexpectedEOF)},
 }, {
 	in:      "[,]",
 	want:    Value{Value: &Array{}},
-	wantErr: fmt.Errorf("hujson: line 1, column 2: %w", errors.New("invalid character ',' at start of value")),
+	wantErr: &SyntaxError{Offset: 1, Line: 1, Column: 2, Char: ',', Expected: "value", Err: errors.New("invalid character ',' at start of value")},
 }, {
 	in: `["s"`,
 	want: Value{Value: &Array{
 		Elements: []Value{{StartOffset: 1, Value: Literal(`"s"`), EndOffset: 4}},
 	}},
-	wantErr: fmt.Errorf("hujson: line 1, column 5: %w", fmt.Errorf("parsing array after value: %w", io.ErrUnexpectedEOF)),
+	wantErr: &SyntaxError{Offset: 4, Line: 1, Column: 5, Expected: "',' or ']'", Err: fmt.Errorf("parsing array after value: %w", io.ErrUnexpectedEOF)},
 }, {
 	in: `["s"}`,
 	want: Value{Value: &Array{
 		Elements: []Value{{StartOffset: 1, Value: Literal(`"s"`), EndOffset: 4}},
 	}},
-	wantErr: fmt.Errorf("hujson: line 1, column 5: %w", errors.New("invalid character '}' after array value (expecting ',' or ']')")),
+	wantErr: &SyntaxError{Offset: 4, Line: 1, Column: 5, Char: '}', Expected: "',' or ']'", Err: errors.New("invalid character '}' after array value (expecting ',' or ']')")},
 }, {
 	in: ` [ "s" ] `,
 	want: Value{
@@ -246,35 +247,35 @@ var testdata = []struct {
 		BeforeExtra: Extra(" "),
 		StartOffset: 1,
 	},
-	wantErr: fmt.Errorf("hujson: line 1, column 2: %w", errors.New("invalid character '\\xff' at start of value")),
+	wantErr: &SyntaxError{Offset: 1, Line: 1, Column: 2, Char: '\xff', Expected: "value", Err: errors.New("invalid character '\\xff' at start of value")},
 }, {
 	in: " '",
 	want: Value{
 		BeforeExtra: Extra(" "),
 		StartOffset: 1,
 	},
-	wantErr: fmt.Errorf("hujson: line 1, column 2: %w", errors.New("invalid character '\\'' at start of value")),
+	wantErr: &SyntaxError{Offset: 1, Line: 1, Column: 2, Char: '\'', Expected: "value", Err: errors.New("invalid character '\\'' at start of value")},
 }, {
 	in: " 💩",
 	want: Value{
 		BeforeExtra: Extra(" "),
 		StartOffset: 1,
 	},
-	wantErr: fmt.Errorf("hujson: line 1, column 2: %w", errors.New("invalid character '💩' at start of value")),
+	wantErr: &SyntaxError{Offset: 1, Line: 1, Column: 2, Char: '\xf0', Expected: "value", Err: errors.New("invalid character '💩' at start of value")},
 }, {
 	in: " \uffff",
 	want: Value{
 		BeforeExtra: Extra(" "),
 		StartOffset: 1,
 	},
-	wantErr: fmt.Errorf("hujson: line 1, column 2: %w", errors.New("invalid character '\\uffff' at start of value")),
+	wantErr: &SyntaxError{Offset: 1, Line: 1, Column: 2, Char: '\xef', Expected: "value", Err: errors.New("invalid character '\\uffff' at start of value")},
 }, {
 	in: " \U00101234",
 	want: Value{
 		BeforeExtra: Extra(" "),
 		StartOffset: 1,
 	},
-	wantErr: fmt.Errorf("hujson: line 1, column 2: %w", errors.New("invalid character '\\U00101234' at start of value")),
+	wantErr: &SyntaxError{Offset: 1, Line: 1, Column: 2, Char: '\xf4', Expected: "value", Err: errors.New("invalid character '\\U00101234' at start of value")},
 }}
 
 func Test(t *testing.T) {
@@ -333,3 +334,60 @@ func Test(t *testing.T) {
 		}
 	}
 }
+
+func TestParseLimits(t *testing.T) {
+	tests := []struct {
+		in      string
+		opts    ParseOptions
+		wantErr error
+	}{{
+		in:   `[[[{"a":[]}]]]`,
+		opts: ParseOptions{MaxDepth: 5},
+	}, {
+		in:      `[[[{"a":[[]]}]]]`,
+		opts:    ParseOptions{MaxDepth: 5},
+		wantErr: &LimitError{Limit: "MaxDepth", Max: 5, Offset: 9, Line: 1, Column: 10},
+	}, {
+		in:      "\n" + strings.Repeat("[", 1e6),
+		opts:    ParseOptions{MaxDepth: 1000},
+		wantErr: &LimitError{Limit: "MaxDepth", Max: 1000, Offset: 1001, Line: 2, Column: 1001},
+	}, {
+		in:   `"hello"`,
+		opts: ParseOptions{MaxBytes: 7},
+	}, {
+		in:      `"hello" `,
+		opts:    ParseOptions{MaxBytes: 7},
+		wantErr: &LimitError{Limit: "MaxBytes", Max: 7, Offset: 7, Line: 1, Column: 8},
+	}, {
+		in:   `{"a":1,"b":2,"c":{"d":4}}`,
+		opts: ParseOptions{MaxMembersPerObject: 3},
+	}, {
+		in:      `{"a":1,"b":2,"c":3,"d":4}`,
+		opts:    ParseOptions{MaxMembersPerObject: 3},
+		wantErr: &LimitError{Limit: "MaxMembersPerObject", Max: 3, Offset: 19, Line: 1, Column: 20},
+	}, {
+		in:   `{"hello":"\u0000"}`,
+		opts: ParseOptions{MaxStringLength: 6},
+	}, {
+		in:      `{"hello":"\u00000"}`,
+		opts:    ParseOptions{MaxStringLength: 6},
+		wantErr: &LimitError{Limit: "MaxStringLength", Max: 6, Offset: 9, Line: 1, Column: 10},
+	}, {
+		in:   "/*1234*/ 0 //1234\n",
+		opts: ParseOptions{MaxCommentLength: 8},
+	}, {
+		in:      "/*1234*/ 0 //123456\n",
+		opts:    ParseOptions{MaxCommentLength: 8},
+		wantErr: &LimitError{Limit: "MaxCommentLength", Max: 8, Offset: 11, Line: 1, Column: 12},
+	}, {
+		in:      "0 /*123456789",
+		opts:    ParseOptions{MaxCommentLength: 8},
+		wantErr: &LimitError{Limit: "MaxCommentLength", Max: 8, Offset: 2, Line: 1, Column: 3},
+	}}
+	for _, tt := range tests {
+		_, gotErr := ParseWithOptions([]byte(tt.in), tt.opts)
+		if !reflect.DeepEqual(gotErr, tt.wantErr) {
+			t.Errorf("ParseWithOptions(%.20q) error mismatch:\ngot  %v\nwant %v", tt.in, gotErr, tt.wantErr)
+		}
+	}
+}
@@ -7,42 +7,67 @@ package hujson
 // UpdateOffsets iterates through v and updates all
 // Value.StartOffset and Value.EndOffset fields so that they are accurate.
 func (v *Value) UpdateOffsets() {
-	v.updateOffsets(0)
-}
-func (v *Value) updateOffsets(n int) int {
-	n += len(v.BeforeExtra)
-	v.StartOffset = n
-	switch v2 := v.Value.(type) {
-	case Literal:
-		n += len(v2)
-	case *Object:
-		n += len("{")
-		for i := range v2.Members {
-			n = v2.Members[i].Name.updateOffsets(n)
-			n += len(":")
-			n = v2.Members[i].Value.updateOffsets(n)
-			n += len(",")
+	var n int
+	var buf [16]packFrame
+	stack := buf[:0]
+	for next := v; ; {
+		// Enter the next value.
+		if next != nil {
+			n += len(next.BeforeExtra)
+			next.StartOffset = n
+			switch v2 := next.Value.(type) {
+			case Literal:
+				n += len(v2)
+				next.EndOffset = n
+				n += len(next.AfterExtra)
+			case composite:
+				n += len("{")
+				stack = append(stack, packFrame{next, v2, 0})
+			}
 		}
-		if v2.length() > 0 && !hasTrailingComma(v2) {
-			n -= len(",")
+		if len(stack) == 0 {
+			return
 		}
-		n += len(v2.AfterExtra)
-		n += len("}")
-	case *Array:
-		n += len("[")
-		for i := range v2.Elements {
-			n = v2.Elements[i].updateOffsets(n)
-			n += len(",")
+
+		// Advance to the next value within the current object or array,
+		// otherwise leave the current object or array.
+		top := &stack[len(stack)-1]
+		if top.next < numValues(top.comp) {
+			n += len(separatorAt(top.comp, top.next))
+			next = valueAt(top.comp, top.next)
+			top.next++
+			continue
 		}
-		if v2.length() > 0 && !hasTrailingComma(v2) {
-			n -= len(",")
+		if hasTrailingComma(top.comp) {
+			n += len(",")
 		}
-		n += len(v2.AfterExtra)
-		n += len("]")
+		n += len(*top.comp.afterExtra())
+		n += len("}")
+		top.v.EndOffset = n
+		n += len(top.v.AfterExtra)
+		stack = stack[:len(stack)-1]
+		next = nil
+	}
+}
+
+// packFrame is an object or array currently being packed.
+type packFrame struct {
+	v    *Value
+	comp composite // the value of v
+	next int       // index of the next value within comp in the order of allValues
+}
+
+// separatorAt returns the separator that precedes the i-th value
+// (in the order of allValues) within comp.
+func separatorAt(comp composite, i int) string {
+	switch {
+	case i == 0:
+		return ""
+	case comp.Kind() == '{' && i%2 == 1:
+		return ":"
+	default:
+		return ","
 	}
-	v.EndOffset = n
-	n += len(v.AfterExtra)
-	return n
 }
 
 // Pack serializes the value as HuJSON.
@@ -58,35 +83,50 @@ func (v Value) String() string {
 }
 
 func (v Value) append(b []byte) []byte {
-	b = append(b, v.BeforeExtra...)
-	switch v2 := v.Value.(type) {
-	case Literal:
-		b = append(b, v2...)
-	case *Object:
-		b = append(b, '{')
-		for _, m := range v2.Members {
-			b = m.Name.append(b)
-			b = append(b, ':')
-			b = m.Value.append(b)
-			b = append(b, ',')
+	// Use an explicit stack rather than recursion so that
+	// deeply nested values do not grow the goroutine stack.
+	var buf [16]packFrame
+	stack := buf[:0]
+	for next := &v; ; {
+		// Enter the next value.
+		if next != nil {
+			b = append(b, next.BeforeExtra...)
+			switch v2 := next.Value.(type) {
+			case Literal:
+				b = append(b, v2...)
+				b = append(b, next.AfterExtra...)
+			case *Object:
+				b = append(b, '{')
+				stack = append(stack, packFrame{next, v2, 0})
+			case *Array:
+				b = append(b, '[')
+				stack = append(stack, packFrame{next, v2, 0})
+			}
+		}
+		if len(stack) == 0 {
+			return b
 		}
-		if v2.length() > 0 && !hasTrailingComma(v2) {
-			b = b[:len(b)-1]
+
+		// Advance to the next value within the current object or array,
+		// otherwise leave the current object or array.
+		top := &stack[len(stack)-1]
+		if top.next < numValues(top.comp) {
+			b = append(b, separatorAt(top.comp, top.next)...)
+			next = valueAt(top.comp, top.next)
+			top.next++
+			continue
 		}
-		b = append(b, v2.AfterExtra...)
-		b = append(b, '}')
-	case *Array:
-		b = append(b, '[')
-		for _, e := range v2.Elements {
-			b = e.append(b)
+		if hasTrailingComma(top.comp) {
 			b = append(b, ',')
 		}
-		if v2.length() > 0 && !hasTrailingComma(v2) {
-			b = b[:len(b)-1]
+		b = append(b, *top.comp.afterExtra()...)
+		if top.comp.Kind() == '{' {
+			b = append(b, '}')
+		} else {
+			b = append(b, ']')
 		}
-		b = append(b, v2.AfterExtra...)
-		b = append(b, ']')
+		b = append(b, top.v.AfterExtra...)
+		stack = stack[:len(stack)-1]
+		next = nil
 	}
-	b = append(b, v.AfterExtra...)
-	return b
 }
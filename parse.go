@@ -22,142 +22,366 @@ func lineColumn(b []byte, n int) (line, column int) {
 // Parse parses a HuJSON value as a Value.
 // Extra and Literal values in v will alias the provided input buffer.
 func Parse(b []byte) (Value, error) {
-	v, n, err := parseNext(0, b)
+	var p parser
+	return p.parse(b)
+}
+
+func (p *parser) parse(b []byte) (Value, error) {
+	v, n, err := p.parseNext(0, b)
 	if err == nil && n < len(b) {
-		err = newInvalidCharacterError(b[n:], "after top-level value")
+		err = newInvalidCharacterError(b[n:], "after top-level value", "end of input")
+	}
+	if err != nil {
+		return v, newPositionedError(err, b, n, 0)
+	}
+	return v, nil
+}
+
+// ParseOptions configures how HuJSON input is parsed.
+// The zero value is equivalent to the behavior of Parse.
+//
+// The limits protect against excessive resource consumption when parsing
+// untrusted input, where a limit of zero means that there is no limit.
+// Input exceeding a limit is rejected with a LimitError.
+type ParseOptions struct {
+	// MaxDepth is the maximum nesting depth of objects and arrays,
+	// where the top-level object or array has a depth of 1.
+	MaxDepth int
+	// MaxBytes is the maximum length of the input in bytes.
+	MaxBytes int
+	// MaxMembersPerObject is the maximum number of members in an object.
+	MaxMembersPerObject int
+	// MaxStringLength is the maximum length in bytes of a JSON string
+	// (including object names) as it appears in the input,
+	// excluding the surrounding quotes.
+	MaxStringLength int
+	// MaxCommentLength is the maximum length in bytes of a comment
+	// as it appears in the input, including the delimiters.
+	MaxCommentLength int
+
+	// RejectDuplicateNames specifies that an object with multiple members
+	// of the same name is rejected with a SyntaxError.
+	// See Value.DuplicateNames for reporting all duplicate names instead.
+	RejectDuplicateNames bool
+
+	// RejectInvalidUnicode specifies that strings (including object names)
+	// containing invalid UTF-8 or an escaped surrogate that is not part of
+	// a valid pair (e.g., "\uD800") are rejected with a SyntaxError.
+	// A leading byte order mark and comments containing invalid UTF-8
+	// are always rejected, but this reports the former more specifically.
+	// See Value.Validate for reporting all such problems instead.
+	RejectInvalidUnicode bool
+}
+
+// ParseWithOptions is like Parse, but parses b according to opts.
+func ParseWithOptions(b []byte, opts ParseOptions) (Value, error) {
+	if opts.MaxBytes > 0 && len(b) > opts.MaxBytes {
+		return Value{}, newPositionedError(newLimitError("MaxBytes", opts.MaxBytes), b, opts.MaxBytes, 0)
+	}
+	if opts.RejectInvalidUnicode && bytes.HasPrefix(b, byteOrderMark) {
+		err := newSyntaxError("value", errors.New("byte order mark is not permitted"))
+		return Value{}, newPositionedError(err, b, 0, 0)
 	}
+	p := parser{opts: opts}
+	v, err := p.parse(b)
 	if err != nil {
-		line, column := lineColumn(b, n)
-		err = fmt.Errorf("hujson: line %d, column %d: %w", line, column, err)
 		return v, err
 	}
+	if opts.RejectDuplicateNames {
+		position := func(n int) (int, int) { return lineColumn(b, n) }
+		if n, err := firstDuplicateName(&v, b, position); err != nil {
+			return v, newPositionedError(err, b, n, 0)
+		}
+	}
 	return v, nil
 }
 
+// firstDuplicateName reports the offset of the first duplicate object name
+// in v (as parsed from b) along with a SyntaxError without any position
+// information, or nil if there are no duplicate names.
+// The position function reports the line and column for an offset in b.
+func firstDuplicateName(v *Value, b []byte, position func(n int) (line, column int)) (int, error) {
+	dups := v.duplicateNames(b)
+	if len(dups) == 0 {
+		return 0, nil
+	}
+	d := dups[0]
+	line, column := position(int(d.First.Offset))
+	return int(d.Duplicate.Offset), newSyntaxError("unique object name", fmt.Errorf("duplicate object name %q (first at line %d, column %d)", d.Name, line, column))
+}
+
+// SyntaxError is a description of a HuJSON syntax error.
+// It is the error type returned by Parse, ParseWithOptions, Format,
+// Standardize, and Minimize, and may be matched using errors.As.
+type SyntaxError struct {
+	// Offset is the byte offset within the input where the error occurred.
+	Offset int64
+	// Line and Column are the 1-based line and column of Offset,
+	// where the column is counted in bytes.
+	Line, Column int
+	// Char is the offending byte at Offset.
+	// It is zero if Offset is at the end of the input.
+	Char byte
+	// Expected describes what was expected at Offset (e.g., "object name").
+	Expected string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("hujson: line %d, column %d: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// LimitError is a description of HuJSON input that exceeds
+// a limit specified in ParseOptions.
+// It is the error type returned by ParseWithOptions for such input,
+// and may be matched using errors.As.
+type LimitError struct {
+	// Limit is the name of the ParseOptions field that was exceeded
+	// (e.g., "MaxDepth").
+	Limit string
+	// Max is the value of the limit that was exceeded.
+	Max int
+	// Offset is the byte offset within the input where the limit was exceeded.
+	Offset int64
+	// Line and Column are the 1-based line and column of Offset,
+	// where the column is counted in bytes.
+	Line, Column int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("hujson: line %d, column %d: exceeded %s of %d", e.Line, e.Column, e.Limit, e.Max)
+}
+
+// newLimitError constructs a LimitError without any position information.
+// The position is populated by newPositionedError.
+func newLimitError(limit string, max int) error {
+	return &LimitError{Limit: limit, Max: max}
+}
+
+// newSyntaxError constructs a SyntaxError without any position information.
+// The position is populated by newPositionedError.
+func newSyntaxError(expected string, err error) error {
+	return &SyntaxError{Expected: expected, Err: err}
+}
+
+// newPositionedError returns a copy of err with the position populated
+// for offset n within b, where b starts at offset base within the input.
+func newPositionedError(err error, b []byte, n int, base int64) error {
+	if e, ok := err.(*LimitError); ok {
+		e2 := *e
+		e2.Offset = base + int64(n)
+		e2.Line, e2.Column = lineColumn(b, min(n, len(b)))
+		return &e2
+	}
+	e, ok := err.(*SyntaxError)
+	if !ok {
+		e = &SyntaxError{Err: err}
+	}
+	e2 := *e // avoid mutating shared sentinel errors
+	e2.Offset = base + int64(n)
+	e2.Line, e2.Column = lineColumn(b, n)
+	if n < len(b) {
+		e2.Char = b[n]
+	}
+	return &e2
+}
+
+// parser parses HuJSON according to the limits in opts.
+// The zero value has no limits.
+type parser struct {
+	opts ParseOptions
+}
+
+// parseFrame is an object or array currently being parsed.
+type parseFrame struct {
+	val     Value   // value for the object or array without Value and EndOffset
+	obj     *Object // non-nil if parsing an object
+	arr     *Array  // non-nil if parsing an array
+	name    Value   // name of the object member whose value is being parsed
+	inValue bool    // whether name is populated and the member value is being parsed
+}
+
+func (f *parseFrame) composite() ValueTrimmed {
+	if f.obj != nil {
+		return f.obj
+	}
+	return f.arr
+}
+
 // parseNext parses the next value with surrounding whitespace and comments.
-func parseNext(n int, b []byte) (v Value, _ int, err error) {
-	n0 := n
+//
+// It uses an explicit stack of objects and arrays (rather than recursion)
+// so that deeply nested input does not grow the goroutine stack.
+// On error, the returned value contains the members and elements
+// of the top-level object or array that were successfully parsed.
+func (p *parser) parseNext(n int, b []byte) (v Value, _ int, err error) {
+	var buf [8]parseFrame
+	stack := buf[:0] // objects and arrays currently being parsed
+	defer func() {
+		if err != nil && len(stack) > 0 {
+			v = stack[0].val
+			v.Value = stack[0].composite()
+		}
+	}()
 
+parseValue:
 	// Consume leading whitespace and comments.
-	if n, err = consumeExtra(n, b); err != nil {
+	v = Value{}
+	n0 := n
+	if n, err = p.consumeExtra(n, b); err != nil {
 		return v, n, err
 	}
 	if n > n0 {
 		v.BeforeExtra = b[n0:n:n]
 	}
+	v.StartOffset = n
 
 	// Parse the next value.
-	v.StartOffset = n
-	if v.Value, n, err = parseNextTrimmed(n, b); err != nil {
+	if len(b) > n {
+		var top *parseFrame
+		if len(stack) > 0 {
+			top = &stack[len(stack)-1]
+		}
+		switch b[n] {
+		case '{', '[':
+			if p.opts.MaxDepth > 0 && len(stack) >= p.opts.MaxDepth {
+				return v, n, newLimitError("MaxDepth", p.opts.MaxDepth)
+			}
+			f := parseFrame{val: v}
+			if b[n] == '{' {
+				f.obj = new(Object)
+			} else {
+				f.arr = new(Array)
+			}
+			stack = append(stack, f)
+			n++
+			goto parseValue
+		case '}':
+			// The end of an object is permitted in place of a member name.
+			if top != nil && top.obj != nil && !top.inValue {
+				setTrailingComma(top.obj, len(top.obj.Members) > 0)
+				top.obj.AfterExtra = v.BeforeExtra
+				n += len(`}`)
+				goto endComposite
+			}
+		case ']':
+			// The end of an array is permitted in place of an element.
+			if top != nil && top.arr != nil {
+				setTrailingComma(top.arr, len(top.arr.Elements) > 0)
+				top.arr.AfterExtra = v.BeforeExtra
+				n += len(`]`)
+				goto endComposite
+			}
+		}
+	}
+	if v.Value, n, err = p.parseLiteral(n, b); err != nil {
 		return v, n, err
 	}
+	goto endValue
+
+endComposite:
+	// Pop the object or array that was just completed.
+	{
+		f := &stack[len(stack)-1]
+		v = f.val
+		v.Value = f.composite()
+		*f = parseFrame{}
+		stack = stack[:len(stack)-1]
+	}
+
+endValue:
 	v.EndOffset = n
 
 	// Consume trailing whitespace and comments.
-	if n, err = consumeExtra(n, b); err != nil {
+	if n, err = p.consumeExtra(n, b); err != nil {
 		return v, n, err
 	}
 	if n > v.EndOffset {
 		v.AfterExtra = b[v.EndOffset:n:n]
 	}
+	if len(stack) == 0 {
+		return v, n, nil
+	}
 
-	return v, n, nil
+	// Handle the value according to the parent object or array.
+	switch top := &stack[len(stack)-1]; {
+	case top.obj != nil && !top.inValue:
+		// Parse the colon.
+		if v.Value.Kind() != '"' {
+			return v, v.StartOffset, newInvalidCharacterError(b[v.StartOffset:], "at start of object name", "object name")
+		}
+		switch {
+		case len(b) == n:
+			return v, n, newSyntaxError("':'", fmt.Errorf("parsing object after name: %w", io.ErrUnexpectedEOF))
+		case b[n] != ':':
+			return v, n, newInvalidCharacterError(b[n:], "after object name", "':'")
+		}
+		n++
+		top.name, top.inValue = v, true
+		goto parseValue
+	case top.obj != nil:
+		obj := top.obj
+		if p.opts.MaxMembersPerObject > 0 && len(obj.Members) >= p.opts.MaxMembersPerObject {
+			return v, top.name.StartOffset, newLimitError("MaxMembersPerObject", p.opts.MaxMembersPerObject)
+		}
+		obj.Members = append(obj.Members, ObjectMember{top.name, v})
+		top.name, top.inValue = Value{}, false
+		switch {
+		case len(b) == n:
+			return v, n, newSyntaxError("',' or '}'", fmt.Errorf("parsing object after value: %w", io.ErrUnexpectedEOF))
+		case b[n] == ',':
+			n++
+			goto parseValue
+		case b[n] == '}':
+			// Move AfterExtra from last value to AfterExtra of the object.
+			obj.AfterExtra = obj.Members[len(obj.Members)-1].Value.AfterExtra
+			obj.Members[len(obj.Members)-1].Value.AfterExtra = nil
+			n += len(`}`)
+			goto endComposite
+		default:
+			return v, n, newInvalidCharacterError(b[n:], "after object value (expecting ',' or '}')", "',' or '}'")
+		}
+	default:
+		arr := top.arr
+		arr.Elements = append(arr.Elements, v)
+		switch {
+		case len(b) == n:
+			return v, n, newSyntaxError("',' or ']'", fmt.Errorf("parsing array after value: %w", io.ErrUnexpectedEOF))
+		case b[n] == ',':
+			n++
+			goto parseValue
+		case b[n] == ']':
+			// Move AfterExtra from last value to AfterExtra of the array.
+			arr.AfterExtra = arr.Elements[len(arr.Elements)-1].AfterExtra
+			arr.Elements[len(arr.Elements)-1].AfterExtra = nil
+			n += len(`]`)
+			goto endComposite
+		default:
+			return v, n, newInvalidCharacterError(b[n:], "after array value (expecting ',' or ']')", "',' or ']'")
+		}
+	}
 }
 
 var (
-	errInvalidObjectEnd = errors.New("invalid character '}' at start of value")
-	errInvalidArrayEnd  = errors.New("invalid character ']' at start of value")
+	errInvalidObjectEnd = newSyntaxError("value", errors.New("invalid character '}' at start of value"))
+	errInvalidArrayEnd  = newSyntaxError("value", errors.New("invalid character ']' at start of value"))
 )
 
-// parseNextTrimmed parses the next value without surrounding whitespace and comments.
-func parseNextTrimmed(n int, b []byte) (ValueTrimmed, int, error) {
+// parseLiteral parses the next null, boolean, string, or number
+// without surrounding whitespace and comments.
+// Objects and arrays are handled by parseNext.
+func (p *parser) parseLiteral(n int, b []byte) (ValueTrimmed, int, error) {
 	if len(b) == n {
-		return nil, n, fmt.Errorf("parsing value: %w", io.ErrUnexpectedEOF)
+		return nil, n, newSyntaxError("value", fmt.Errorf("parsing value: %w", io.ErrUnexpectedEOF))
 	}
 	switch b[n] {
-	// Parse objects.
-	case '{':
-		n++
-		var obj Object
-		for {
-			var vk, vv Value
-			var err error
-
-			// Parse the name.
-			if vk, n, err = parseNext(n, b); err != nil {
-				if err == errInvalidObjectEnd && vk.Value == nil {
-					setTrailingComma(&obj, len(obj.Members) > 0)
-					obj.AfterExtra = vk.BeforeExtra
-					return &obj, n + len(`}`), nil
-				}
-				return &obj, n, err
-			}
-			if vk.Value.Kind() != '"' {
-				return &obj, vk.StartOffset, newInvalidCharacterError(b[vk.StartOffset:], "at start of object name")
-			}
-
-			// Parse the colon.
-			switch {
-			case len(b) == n:
-				return &obj, n, fmt.Errorf("parsing object after name: %w", io.ErrUnexpectedEOF)
-			case b[n] != ':':
-				return &obj, n, newInvalidCharacterError(b[n:], "after object name")
-			}
-			n++
-
-			// Parse the value.
-			if vv, n, err = parseNext(n, b); err != nil {
-				return &obj, n, err
-			}
-
-			obj.Members = append(obj.Members, ObjectMember{vk, vv})
-			switch {
-			case len(b) == n:
-				return &obj, n, fmt.Errorf("parsing object after value: %w", io.ErrUnexpectedEOF)
-			case b[n] == ',':
-				n++
-			case b[n] == '}':
-				// Move AfterExtra from last value to AfterExtra of the object.
-				obj.AfterExtra = obj.Members[len(obj.Members)-1].Value.AfterExtra
-				obj.Members[len(obj.Members)-1].Value.AfterExtra = nil
-				return &obj, n + len(`}`), nil
-			default:
-				return &obj, n, newInvalidCharacterError(b[n:], "after object value (expecting ',' or '}')")
-			}
-		}
 	case '}':
 		return nil, n, errInvalidObjectEnd
-
-	// Parse arrays.
-	case '[':
-		n++
-		var arr Array
-		for {
-			var v Value
-			var err error
-			if v, n, err = parseNext(n, b); err != nil {
-				if err == errInvalidArrayEnd && v.Value == nil {
-					setTrailingComma(&arr, len(arr.Elements) > 0)
-					arr.AfterExtra = v.BeforeExtra
-					return &arr, n + len(`]`), nil
-				}
-				return &arr, n, err
-			}
-			arr.Elements = append(arr.Elements, v)
-			switch {
-			case len(b) == n:
-				return &arr, n, fmt.Errorf("parsing array after value: %w", io.ErrUnexpectedEOF)
-			case b[n] == ',':
-				n++
-			case b[n] == ']':
-				// Move AfterExtra from last value to AfterExtra of the array.
-				arr.AfterExtra = arr.Elements[len(arr.Elements)-1].AfterExtra
-				arr.Elements[len(arr.Elements)-1].AfterExtra = nil
-				return &arr, n + len(`]`), nil
-			default:
-				return &arr, n, newInvalidCharacterError(b[n:], "after array value (expecting ',' or ']')")
-			}
-		}
 	case ']':
 		return nil, n, errInvalidArrayEnd
 
@@ -168,8 +392,10 @@ func parseNextTrimmed(n int, b []byte) (ValueTrimmed, int, error) {
 		var inEscape bool
 		for {
 			switch {
+			case p.opts.MaxStringLength > 0 && n-(n0+len(`"`)) > p.opts.MaxStringLength:
+				return nil, n0, newLimitError("MaxStringLength", p.opts.MaxStringLength)
 			case len(b) == n:
-				return nil, n, fmt.Errorf("parsing string: %w", io.ErrUnexpectedEOF)
+				return nil, n, newSyntaxError("end of string", fmt.Errorf("parsing string: %w", io.ErrUnexpectedEOF))
 			case inEscape:
 				inEscape = false
 			case b[n] == '\\':
@@ -178,7 +404,12 @@ func parseNextTrimmed(n int, b []byte) (ValueTrimmed, int, error) {
 				n++
 				lit := Literal(b[n0:n:n])
 				if !lit.IsValid() {
-					return nil, n0, fmt.Errorf("invalid literal: %s", lit)
+					return nil, n0, newSyntaxError("valid string", fmt.Errorf("invalid literal: %s", lit))
+				}
+				if p.opts.RejectInvalidUnicode {
+					if i, err := lit.invalidUnicode(); err != nil {
+						return nil, n0 + i, newSyntaxError("valid Unicode in string", fmt.Errorf("%w in string", err))
+					}
 				}
 				return lit, n, nil
 			}
@@ -196,9 +427,9 @@ func parseNextTrimmed(n int, b []byte) (ValueTrimmed, int, error) {
 		}
 		switch lit := Literal(b[n0:n:n]); {
 		case len(lit) == 0:
-			return nil, n0, newInvalidCharacterError(b[n0:], "at start of value")
+			return nil, n0, newInvalidCharacterError(b[n0:], "at start of value", "value")
 		case !lit.IsValid():
-			return nil, n0, fmt.Errorf("invalid literal: %s", lit)
+			return nil, n0, newSyntaxError("null, boolean, or number", fmt.Errorf("invalid literal: %s", lit))
 		default:
 			return lit, n, nil
 		}
@@ -214,6 +445,10 @@ var (
 
 // consumeExtra consumes leading whitespace and comments.
 func consumeExtra(n int, b []byte) (int, error) {
+	var p parser
+	return p.consumeExtra(n, b)
+}
+func (p *parser) consumeExtra(n int, b []byte) (int, error) {
 	for len(b) > n {
 		switch b[n] {
 		// Skip past whitespace.
@@ -224,10 +459,12 @@ func consumeExtra(n int, b []byte) (int, error) {
 			switch nc := consumeComment(b[n:]); {
 			case nc == 0:
 				return n, nil
+			case p.opts.MaxCommentLength > 0 && (nc > p.opts.MaxCommentLength || (nc < 0 && len(b)-n > p.opts.MaxCommentLength)):
+				return n, newLimitError("MaxCommentLength", p.opts.MaxCommentLength)
 			case nc < 0:
-				return n, fmt.Errorf("parsing comment: %w", io.ErrUnexpectedEOF)
+				return n, newSyntaxError("end of comment", fmt.Errorf("parsing comment: %w", io.ErrUnexpectedEOF))
 			case !utf8.Valid(b[n : n+nc]):
-				return n, fmt.Errorf("invalid UTF-8 in comment")
+				return n, newSyntaxError("valid UTF-8 in comment", fmt.Errorf("invalid UTF-8 in comment"))
 			default:
 				n += nc
 			}
@@ -265,7 +502,7 @@ func consumeComment(b []byte) (n int) {
 	return len(start) + i + len(end)
 }
 
-func newInvalidCharacterError(prefix []byte, where string) error {
+func newInvalidCharacterError(prefix []byte, where, expected string) error {
 	var what string
 	r, n := utf8.DecodeRune(prefix)
 	switch {
@@ -278,5 +515,5 @@ func newInvalidCharacterError(prefix []byte, where string) error {
 	default:
 		what = fmt.Sprintf(`'\U%08x'`, r)
 	}
-	return errors.New("invalid character " + what + " " + where)
+	return newSyntaxError(expected, errors.New("invalid character "+what+" "+where))
 }
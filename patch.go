@@ -6,9 +6,9 @@ package hujson
 
 import (
 	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"reflect"
+	"slices"
 	"strings"
 )
 
@@ -39,39 +39,278 @@ import (
 // The patch file may be in the HuJSON format where comments around and within
 // a value being inserted are preserved. If the patch fails to fully apply,
 // the receiver value will be left in a partially mutated state.
-// Use Clone to preserve the original value.
+// Use Clone or PatchWithOptions with PatchOptions.Atomic
+// to preserve the original value.
 //
 // It does not format the value. It is recommended that Format be called after
 // applying a patch.
 func (v *Value) Patch(patch []byte) error {
+	return v.PatchWithOptions(patch, PatchOptions{})
+}
+
+// PatchOptions configures how a patch is applied.
+type PatchOptions struct {
+	// Atomic specifies that the receiver value is restored to its
+	// original state if the patch fails to fully apply.
+	// Rather than cloning the entire value upfront, it records the original
+	// contents of each object and array before it is first mutated.
+	Atomic bool
+}
+
+// PatchWithOptions is like Patch, but applies the patch according to opts.
+func (v *Value) PatchWithOptions(patch []byte, opts PatchOptions) error {
 	ops, err := parsePatch(patch)
 	if err != nil {
-		return err
+		return positionPatchError(err, patch)
+	}
+	var undo *undoLog
+	if opts.Atomic {
+		undo = &undoLog{root: *v}
 	}
-	for i, op := range ops {
+	for _, op := range ops {
 		var err error
 		switch op.op {
 		case "add":
-			err = v.patchAdd(i, op)
+			err = v.patchAdd(op, undo)
 		case "remove", "replace":
-			err = v.patchRemoveOrReplace(i, op)
+			err = v.patchRemoveOrReplace(op, undo)
 		case "move", "copy":
-			err = v.patchMoveOrCopy(i, op)
+			err = v.patchMoveOrCopy(op, undo)
 		case "test":
-			err = v.patchTest(i, op)
+			err = v.patchTest(op)
 		}
 		if err != nil {
-			return err
+			undo.restore(v)
+			return positionPatchError(err, patch)
 		}
 	}
 	return nil
 }
 
+// PatchError is a description of a failure to apply a JSON Patch.
+// It is the error type returned by Patch and PatchWithOptions for
+// patches that are syntactically valid, and may be matched using errors.As.
+type PatchError struct {
+	// OpIndex is the index of the failing operation within the patch.
+	// It is -1 if the patch itself is not a JSON array.
+	OpIndex int
+	// Op, Path, and From are the "op", "path", and "from" members of
+	// the failing operation. Each is empty if not yet parsed when
+	// the patch operation is invalid.
+	Op, Path, From string
+	// Kind classifies the failure.
+	Kind PatchErrorKind
+	// Offset is the byte offset of the failing operation within the patch.
+	Offset int64
+	// Line and Column are the 1-based line and column of Offset,
+	// where the column is counted in bytes.
+	Line, Column int
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *PatchError) Error() string {
+	if e.OpIndex < 0 {
+		return fmt.Sprintf("hujson: %v", e.Err)
+	}
+	return fmt.Sprintf("hujson: patch operation %d: %v", e.OpIndex, e.Err)
+}
+
+func (e *PatchError) Unwrap() error {
+	return e.Err
+}
+
+// PatchErrorKind classifies the reason that a patch failed to apply.
+type PatchErrorKind int
+
+const (
+	_ PatchErrorKind = iota
+	// PatchInvalid reports that the patch or an operation is malformed
+	// (e.g., a missing required member or an unknown operation).
+	PatchInvalid
+	// PatchTestFailed reports that a "test" operation found a different value.
+	PatchTestFailed
+	// PatchPathNotFound reports that a "path" or "from" pointer
+	// references a value that does not exist.
+	PatchPathNotFound
+	// PatchInvalidPointer reports that a "path" or "from" pointer is malformed
+	// (e.g., it lacks a leading slash or has an invalid array index).
+	PatchInvalidPointer
+	// PatchTypeMismatch reports that a pointer indexes into a literal
+	// rather than an object or array.
+	PatchTypeMismatch
+	// PatchInvalidTarget reports that an operation cannot be applied to
+	// its target (e.g., removing the root value or
+	// moving a value into one of its own children).
+	PatchInvalidTarget
+)
+
+func (k PatchErrorKind) String() string {
+	switch k {
+	case PatchInvalid:
+		return "invalid patch"
+	case PatchTestFailed:
+		return "test failed"
+	case PatchPathNotFound:
+		return "path not found"
+	case PatchInvalidPointer:
+		return "invalid pointer"
+	case PatchTypeMismatch:
+		return "type mismatch"
+	case PatchInvalidTarget:
+		return "invalid target"
+	default:
+		return fmt.Sprintf("PatchErrorKind(%d)", int(k))
+	}
+}
+
+// positionPatchError populates the line and column of a PatchError
+// according to its offset within the patch.
+func positionPatchError(err error, patch []byte) error {
+	if e, ok := err.(*PatchError); ok {
+		e.Line, e.Column = lineColumn(patch, int(e.Offset))
+	}
+	return err
+}
+
+// MergePatch patches the value according to the provided merge patch
+// (per RFC 7386). Objects in the patch are recursively merged into the value,
+// where members with a null value are removed from the value.
+// All other values in the patch replace the corresponding values.
+// The merge patch may be in the HuJSON format where comments around and within
+// a value being inserted are preserved. Comments on members that are
+// left untouched by the patch are preserved as is.
+//
+// It does not format the value. It is recommended that Format be called after
+// applying a merge patch.
+func (v *Value) MergePatch(patch []byte) error {
+	p, err := Parse(patch)
+	if err != nil {
+		return err
+	}
+	pobj, ok := p.Value.(*Object)
+	if !ok {
+		v.Value = p.Value
+		return nil
+	}
+	obj, ok := v.Value.(*Object)
+	if !ok {
+		obj = new(Object)
+		v.Value = obj
+	}
+	obj.mergePatch(pobj)
+	return nil
+}
+
+func (obj *Object) mergePatch(patch *Object) {
+	// Nested objects are merged using a queue rather than recursion.
+	// Merges into the same object still occur in the order of the patch,
+	// and a merge into an object that has since been replaced has no effect,
+	// so the result is the same as merging recursively.
+	type merge struct{ obj, patch *Object }
+	queue := []merge{{obj, patch}}
+	for len(queue) > 0 {
+		obj, patch := queue[0].obj, queue[0].patch
+		queue = queue[1:]
+		for j := range patch.Members {
+			name := patch.Members[j].Name.Value.(Literal).String()
+			idx := slices.IndexFunc(obj.Members, func(m ObjectMember) bool {
+				return m.Name.Value.(Literal).equalString(name)
+			})
+
+			// Remove members with a null value.
+			value := patch.Members[j].Value
+			if value.Value.Kind() == 'n' {
+				if idx >= 0 {
+					removeAt(obj, idx)
+				}
+				continue
+			}
+
+			// Merge objects.
+			if pobj, ok := value.Value.(*Object); ok {
+				if idx >= 0 {
+					if obj2, ok := obj.Members[idx].Value.Value.(*Object); ok {
+						queue = append(queue, merge{obj2, pobj})
+						continue
+					}
+				}
+				pobj.removeNulls()
+			}
+
+			// Otherwise, replace or insert the value.
+			value.BeforeExtra = patch.beforeExtraAt(j + 0).extractLeadingComments(true)
+			value.AfterExtra = patch.beforeExtraAt(j + 1).extractTrailingcomments(true)
+			if idx >= 0 {
+				replaceAt(obj, idx, value)
+			} else {
+				insertAt(obj, obj.length(), value)
+				obj.Members[obj.length()-1].Name.Value = patch.Members[j].Name.Value
+			}
+		}
+	}
+}
+
+// removeNulls removes all object members with a null value
+// from obj and all objects nested within it.
+func (obj *Object) removeNulls() {
+	stack := []*Object{obj}
+	for len(stack) > 0 {
+		obj := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for i := 0; i < len(obj.Members); {
+			switch v := obj.Members[i].Value.Value.(type) {
+			case Literal:
+				if v.Kind() == 'n' {
+					removeAt(obj, i)
+					continue
+				}
+			case *Object:
+				stack = append(stack, v)
+			}
+			i++
+		}
+	}
+}
+
 type patchOperation struct {
-	op    string // "add" | "remove" | "replace" | "move" | "copy" | "test"
-	path  string // used by all operations
-	from  string // used by "move" and "copy"
-	value Value  // used by "add", "replace", and "test"
+	index  int    // index of the operation within the patch
+	offset int    // offset of the operation within the patch
+	op     string // "add" | "remove" | "replace" | "move" | "copy" | "test"
+	path   string // used by all operations
+	from   string // used by "move" and "copy"
+	value  Value  // used by "add", "replace", and "test"
+}
+
+// error constructs a PatchError for the operation.
+func (op *patchOperation) error(kind PatchErrorKind, err error) error {
+	return &PatchError{
+		OpIndex: op.index,
+		Op:      op.op,
+		Path:    op.path,
+		From:    op.from,
+		Kind:    kind,
+		Offset:  int64(op.offset),
+		Err:     err,
+	}
+}
+
+// errorf constructs a PatchError for the operation with a formatted message.
+func (op *patchOperation) errorf(kind PatchErrorKind, format string, args ...any) error {
+	return op.error(kind, fmt.Errorf(format, args...))
+}
+
+// pointerError constructs a PatchError for a JSON pointer that
+// could not be resolved by Value.find.
+func (op *patchOperation) pointerError(err error) error {
+	kind := PatchInvalidPointer
+	switch {
+	case errors.Is(err, errNotFound):
+		kind = PatchPathNotFound
+	case errors.Is(err, errIndexLiteral):
+		kind = PatchTypeMismatch
+	}
+	return op.error(kind, err)
 }
 
 func parsePatch(patch []byte) ([]patchOperation, error) {
@@ -81,41 +320,42 @@ func parsePatch(patch []byte) ([]patchOperation, error) {
 	}
 	arr, ok := v.Value.(*Array)
 	if !ok {
-		return nil, fmt.Errorf("hujson: patch must be a JSON array")
+		op := patchOperation{index: -1, offset: v.StartOffset}
+		return nil, op.errorf(PatchInvalid, "patch must be a JSON array")
 	}
 	var ops []patchOperation
 	for i, e := range arr.Elements {
+		op := patchOperation{index: i, offset: e.StartOffset}
 		obj, ok := e.Value.(*Object)
 		if !ok {
-			return nil, fmt.Errorf("hujson: patch operation %d: must be a JSON object", i)
+			return nil, op.errorf(PatchInvalid, "must be a JSON object")
 		}
 		seen := make(map[string]bool)
-		var op patchOperation
 		for j, m := range obj.Members {
 			name := m.Name.Value.(Literal).String()
 			if seen[name] {
-				return nil, fmt.Errorf("hujson: patch operation %d: duplicate name %q", i, m.Name.Value)
+				return nil, op.errorf(PatchInvalid, "duplicate name %q", m.Name.Value)
 			}
 			seen[name] = true
 			switch name {
 			case "op":
 				if m.Value.Value.Kind() != '"' {
-					return nil, fmt.Errorf("hujson: patch operation %d: member %q must be a JSON string", i, name)
+					return nil, op.errorf(PatchInvalid, "member %q must be a JSON string", name)
 				}
 				switch opType := m.Value.Value.(Literal).String(); opType {
 				case "add", "remove", "replace", "move", "copy", "test":
 					op.op = opType
 				default:
-					return nil, fmt.Errorf("hujson: patch operation %d: unknown operation %q", i, m.Value.Value)
+					return nil, op.errorf(PatchInvalid, "unknown operation %q", m.Value.Value)
 				}
 			case "path":
 				if m.Value.Value.Kind() != '"' {
-					return nil, fmt.Errorf("hujson: patch operation %d: member %q must be a JSON string", i, name)
+					return nil, op.errorf(PatchInvalid, "member %q must be a JSON string", name)
 				}
 				op.path = m.Value.Value.(Literal).String()
 			case "from":
 				if m.Value.Value.Kind() != '"' {
-					return nil, fmt.Errorf("hujson: patch operation %d: member %q must be a JSON string", i, name)
+					return nil, op.errorf(PatchInvalid, "member %q must be a JSON string", name)
 				}
 				op.from = m.Value.Value.(Literal).String()
 			case "value":
@@ -126,27 +366,28 @@ func parsePatch(patch []byte) ([]patchOperation, error) {
 		}
 		switch {
 		case !seen["op"]:
-			return nil, fmt.Errorf("hujson: patch operation %d: missing required member %q", i, "op")
+			return nil, op.errorf(PatchInvalid, "missing required member %q", "op")
 		case !seen["path"]:
-			return nil, fmt.Errorf("hujson: patch operation %d: missing required member %q", i, "path")
+			return nil, op.errorf(PatchInvalid, "missing required member %q", "path")
 		case !seen["from"] && (op.op == "move" || op.op == "copy"):
-			return nil, fmt.Errorf("hujson: patch operation %d: missing required member %q", i, "from")
+			return nil, op.errorf(PatchInvalid, "missing required member %q", "from")
 		case !seen["value"] && (op.op == "add" || op.op == "replace" || op.op == "test"):
-			return nil, fmt.Errorf("hujson: patch operation %d: missing required member %q", i, "value")
+			return nil, op.errorf(PatchInvalid, "missing required member %q", "value")
 		}
 		ops = append(ops, op)
 	}
 	return ops, nil
 }
 
-func (v *Value) patchAdd(i int, op patchOperation) error {
+func (v *Value) patchAdd(op patchOperation, undo *undoLog) error {
 	s, err := v.find(findState{pointer: op.path})
 	if err != nil && (err != errNotFound || len(s.pointer) != s.offset) {
-		return fmt.Errorf("hujson: patch operation %d: %v", i, err)
+		return op.pointerError(err)
 	}
 	if s.parent == nil {
 		*v = op.value // only occurs for root
 	} else {
+		undo.save(s.parent)
 		switch comp := s.parent.(type) {
 		case *Object:
 			if s.idx < comp.length() {
@@ -162,14 +403,15 @@ func (v *Value) patchAdd(i int, op patchOperation) error {
 	return nil
 }
 
-func (v *Value) patchRemoveOrReplace(i int, op patchOperation) error {
+func (v *Value) patchRemoveOrReplace(op patchOperation, undo *undoLog) error {
 	s, err := v.find(findState{pointer: op.path})
 	if err != nil {
-		return fmt.Errorf("hujson: patch operation %d: %v", i, err)
+		return op.pointerError(err)
 	}
 	if s.parent == nil {
-		return fmt.Errorf("hujson: patch operation %d: cannot %s root value", i, op.op)
+		return op.errorf(PatchInvalidTarget, "cannot %s root value", op.op)
 	}
+	undo.save(s.parent)
 	switch op.op {
 	case "remove":
 		removeAt(s.parent, s.idx)
@@ -179,37 +421,87 @@ func (v *Value) patchRemoveOrReplace(i int, op patchOperation) error {
 	return nil
 }
 
-func (v *Value) patchMoveOrCopy(i int, op patchOperation) error {
+func (v *Value) patchMoveOrCopy(op patchOperation, undo *undoLog) error {
 	if op.from == "" || (op.op == "move" && hasPathPrefix(op.path, op.from)) {
-		return fmt.Errorf("hujson: patch operation %d: cannot %s %q into %q", i, op.op, op.from, op.path)
+		return op.errorf(PatchInvalidTarget, "cannot %s %q into %q", op.op, op.from, op.path)
 	}
 	sFrom, err := v.find(findState{pointer: op.from})
 	if err != nil {
-		return fmt.Errorf("hujson: patch operation %d: %v", i, err)
+		return op.pointerError(err)
 	}
 	// TODO(dsnet): For a move operation within the same object,
 	// we should simplify this as just a rename or replace.
 	switch op.op {
 	case "move":
+		undo.save(sFrom.parent)
 		op.value = removeAt(sFrom.parent, sFrom.idx)
 	case "copy":
 		op.value = copyAt(sFrom.parent, sFrom.idx)
 	}
-	return v.patchAdd(i, op)
+	return v.patchAdd(op, undo)
 }
 
-func (v *Value) patchTest(i int, op patchOperation) error {
+func (v *Value) patchTest(op patchOperation) error {
 	s, err := v.find(findState{pointer: op.path})
 	if err != nil {
-		return fmt.Errorf("hujson: patch operation %d: %v", i, err)
+		return op.pointerError(err)
 	}
 	if !equalValue(*s.value, op.value) {
-		return fmt.Errorf("hujson: patch operation %d: values differ at %q", i, op.path)
+		return op.errorf(PatchTestFailed, "values differ at %q", op.path)
 
 	}
 	return nil
 }
 
+// undoLog records the original state of a value being patched
+// such that it can be restored if the patch fails to apply.
+// A nil undoLog records nothing.
+type undoLog struct {
+	root  Value // the original root value
+	saved map[composite]bool
+	undo  []func()
+}
+
+// save records the original state of comp if not already recorded.
+// It must be called before comp is mutated.
+//
+// The comment manipulation functions may modify the backing arrays of
+// Extra values in place, so they are copied as part of the saved state.
+func (u *undoLog) save(comp composite) {
+	if u == nil || u.saved[comp] {
+		return
+	}
+	if u.saved == nil {
+		u.saved = make(map[composite]bool)
+	}
+	u.saved[comp] = true
+	switch comp := comp.(type) {
+	case *Object:
+		orig := Object{Members: slices.Clone(comp.Members), AfterExtra: copyBytes(comp.AfterExtra)}
+		for i := range orig.Members {
+			orig.Members[i].Name.BeforeExtra = copyBytes(orig.Members[i].Name.BeforeExtra)
+		}
+		u.undo = append(u.undo, func() { *comp = orig })
+	case *Array:
+		orig := Array{Elements: slices.Clone(comp.Elements), AfterExtra: copyBytes(comp.AfterExtra)}
+		for i := range orig.Elements {
+			orig.Elements[i].BeforeExtra = copyBytes(orig.Elements[i].BeforeExtra)
+		}
+		u.undo = append(u.undo, func() { *comp = orig })
+	}
+}
+
+// restore restores v to the state recorded in the undo log.
+func (u *undoLog) restore(v *Value) {
+	if u == nil {
+		return
+	}
+	for i := len(u.undo) - 1; i >= 0; i-- {
+		u.undo[i]()
+	}
+	*v = u.root
+}
+
 // hasPathPrefix is a stricter version of strings.HasPrefix where
 // the prefix must end on a path segment boundary.
 func hasPathPrefix(s, prefix string) bool {
@@ -220,22 +512,64 @@ func hasPathPrefix(s, prefix string) bool {
 }
 
 func equalValue(x, y Value) bool {
-	// TODO(dsnet): This definition of equality is both naive and slow.
-	//	* It fails to properly compare strings with invalid UTF-8.
-	//	* It fails to precisely compare integers beyond ±2⁵³.
-	//	* It cannot handle values greater than ±math.MaxFloat64.
-	//	* Comparison of objects with duplicate names has undefined behavior.
-	unmarshal := func(v Value) (vi interface{}) {
-		v = v.Clone()
-		v.Standardize()
-		if json.Unmarshal(v.Pack(), &vi) != nil {
-			return nil
+	// TODO(dsnet): Comparison of objects with duplicate names has
+	// undefined behavior. The last member with a given name is used.
+	type pair struct{ x, y ValueTrimmed }
+	stack := []pair{{x.Value, y.Value}}
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch vx := p.x.(type) {
+		case Literal:
+			vy, ok := p.y.(Literal)
+			if !ok || !vx.IsValid() || !vy.IsValid() || !equalLiteral(vx, vy) {
+				return false
+			}
+		case *Object:
+			vy, ok := p.y.(*Object)
+			if !ok {
+				return false
+			}
+			mx, okx := objectMembers(vx)
+			my, oky := objectMembers(vy)
+			if !okx || !oky || len(mx) != len(my) {
+				return false
+			}
+			for name, vx := range mx {
+				vy, ok := my[name]
+				if !ok {
+					return false
+				}
+				stack = append(stack, pair{vx, vy})
+			}
+		case *Array:
+			vy, ok := p.y.(*Array)
+			if !ok || len(vx.Elements) != len(vy.Elements) {
+				return false
+			}
+			for i := range vx.Elements {
+				stack = append(stack, pair{vx.Elements[i].Value, vy.Elements[i].Value})
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// objectMembers returns the members of obj keyed by the exact decoding
+// of each name, such that names differing only in their escaping are equal.
+// It reports false if any name is not a valid JSON string.
+func objectMembers(obj *Object) (map[string]ValueTrimmed, bool) {
+	members := make(map[string]ValueTrimmed, len(obj.Members))
+	for _, m := range obj.Members {
+		name, ok := m.Name.Value.(Literal)
+		if !ok || name.Kind() != '"' || !name.IsValid() {
+			return nil, false
 		}
-		return vi
+		members[name.exactKey()] = m.Value.Value
 	}
-	vx := unmarshal(x)
-	vy := unmarshal(y)
-	return reflect.DeepEqual(vx, vy) && vx != nil && vy != nil
+	return members, true
 }
 
 func (obj *Object) getAt(i int) ValueTrimmed {
@@ -91,7 +91,7 @@ var testdataPatch = []struct {
 	// RFC 6902, appendix A.9.
 	in:      `{ "baz": "qux" }`,
 	patch:   `[{ "op": "test", "path": "/baz", "value": "bar" }]`,
-	wantErr: errors.New(`hujson: patch operation 0: values differ at "/baz"`),
+	wantErr: &PatchError{OpIndex: 0, Op: "test", Path: "/baz", Kind: PatchTestFailed, Offset: 1, Line: 1, Column: 2, Err: errors.New(`values differ at "/baz"`)},
 }, {
 	// RFC 6902, appendix A.10.
 	in:    `{ "foo": "bar" }`,
@@ -107,13 +107,13 @@ var testdataPatch = []struct {
 	in:      `{ "foo": "bar" }`,
 	patch:   `[{ "op": "add", "path": "/baz/bat", "value": "qux" }]`,
 	want:    `{ "foo": "bar" }`,
-	wantErr: errors.New("hujson: patch operation 0: value not found"),
+	wantErr: &PatchError{OpIndex: 0, Op: "add", Path: "/baz/bat", Kind: PatchPathNotFound, Offset: 1, Line: 1, Column: 2, Err: errNotFound},
 }, {
 	// RFC 6902, appendix A.13.
 	in:      `null`,
 	patch:   `[{ "op": "add", "path": "/baz", "value": "qux", "op": "remove" }]`,
 	want:    `null`,
-	wantErr: errors.New(`hujson: patch operation 0: duplicate name "op"`),
+	wantErr: &PatchError{OpIndex: 0, Op: "add", Path: "/baz", Kind: PatchInvalid, Offset: 1, Line: 1, Column: 2, Err: errors.New(`duplicate name "op"`)},
 }, {
 	// RFC 6902, appendix A.14.
 	in:    `{ "/": 9, "~1": 10 }`,
@@ -122,7 +122,7 @@ var testdataPatch = []struct {
 	// RFC 6902, appendix A.15.
 	in:      `{ "/": 9, "~1": 10 }`,
 	patch:   `[{ "op": "test", "path": "/~01", "value": "10" }]`,
-	wantErr: errors.New(`hujson: patch operation 0: values differ at "/~01"`),
+	wantErr: &PatchError{OpIndex: 0, Op: "test", Path: "/~01", Kind: PatchTestFailed, Offset: 1, Line: 1, Column: 2, Err: errors.New(`values differ at "/~01"`)},
 }, {
 	// RFC 6902, appendix A.16.
 	in:    `{ "foo": ["bar"] }`,
@@ -135,6 +135,18 @@ var testdataPatch = []struct {
 	"foo": "bar"
 }`,
 	patch: `[{ "op": "test", "path": "", "value": {"foo":"bar","\u0066izz":"buzz"} }]`,
+}, {
+	// Test operation should compare numbers exactly.
+	in:    `[9007199254740992, 1e400]`,
+	patch: `[{ "op": "test", "path": "", "value": [9007199254740992.0, 10E399] }]`,
+}, {
+	in:      `9007199254740992`,
+	patch:   `[{ "op": "test", "path": "", "value": 9007199254740993 }]`,
+	wantErr: &PatchError{OpIndex: 0, Op: "test", Path: "", Kind: PatchTestFailed, Offset: 1, Line: 1, Column: 2, Err: errors.New(`values differ at ""`)},
+}, {
+	in:      `{"x": "\ud800"}`,
+	patch:   `[{ "op": "test", "path": "/x", "value": "\udc00" }]`,
+	wantErr: &PatchError{OpIndex: 0, Op: "test", Path: "/x", Kind: PatchTestFailed, Offset: 1, Line: 1, Column: 2, Err: errors.New(`values differ at "/x"`)},
 }, {
 	in:    `"hello"`,
 	patch: `[{ "op": "add", "path": "", "value": "goodbye" }]`,
@@ -142,11 +154,11 @@ var testdataPatch = []struct {
 }, {
 	in:      `"hello"`,
 	patch:   `[{ "op": "remove", "path": "" }]`,
-	wantErr: errors.New(`hujson: patch operation 0: cannot remove root value`),
+	wantErr: &PatchError{OpIndex: 0, Op: "remove", Path: "", Kind: PatchInvalidTarget, Offset: 1, Line: 1, Column: 2, Err: errors.New(`cannot remove root value`)},
 }, {
 	in:      `{}`,
 	patch:   `[{ "op": "remove", "path": "/noexist" }]`,
-	wantErr: errors.New(`hujson: patch operation 0: value not found`),
+	wantErr: &PatchError{OpIndex: 0, Op: "remove", Path: "/noexist", Kind: PatchPathNotFound, Offset: 1, Line: 1, Column: 2, Err: errNotFound},
 }, {
 	in:    `{"hello":"goodbye","fizz":"buzz"}`,
 	patch: `[{ "op": "add", "path": "/hello", "value": "bonjour" }]`,
@@ -158,15 +170,15 @@ var testdataPatch = []struct {
 }, {
 	in:      `{"hello":"goodbye","fizz":"buzz"}`,
 	patch:   `[{ "op": "move", "from": "", "path": "/fizz" }]`,
-	wantErr: errors.New(`hujson: patch operation 0: cannot move "" into "/fizz"`),
+	wantErr: &PatchError{OpIndex: 0, Op: "move", Path: "/fizz", Kind: PatchInvalidTarget, Offset: 1, Line: 1, Column: 2, Err: errors.New(`cannot move "" into "/fizz"`)},
 }, {
 	in:      `{"fizz":["buzz","wuzz"],"fizzy":"wizzy"}`,
 	patch:   `[{ "op": "move", "from": "/fizz", "path": "/fizz" }]`,
-	wantErr: errors.New(`hujson: patch operation 0: cannot move "/fizz" into "/fizz"`),
+	wantErr: &PatchError{OpIndex: 0, Op: "move", Path: "/fizz", From: "/fizz", Kind: PatchInvalidTarget, Offset: 1, Line: 1, Column: 2, Err: errors.New(`cannot move "/fizz" into "/fizz"`)},
 }, {
 	in:      `{"fizz":["buzz","wuzz"],"fizzy":"wizzy"}`,
 	patch:   `[{ "op": "move", "from": "/fizz", "path": "/fizz/wuzz" }]`,
-	wantErr: errors.New(`hujson: patch operation 0: cannot move "/fizz" into "/fizz/wuzz"`),
+	wantErr: &PatchError{OpIndex: 0, Op: "move", Path: "/fizz/wuzz", From: "/fizz", Kind: PatchInvalidTarget, Offset: 1, Line: 1, Column: 2, Err: errors.New(`cannot move "/fizz" into "/fizz/wuzz"`)},
 }, {
 	in:    `{"fizz":["buzz","wuzz"],"fizzy":"wizzy"}`,
 	patch: `[{ "op": "move", "from": "/fizz", "path": "/fizzy" }]`,
@@ -174,80 +186,78 @@ var testdataPatch = []struct {
 }, {
 	in:      `{"fizz":["buzz","wuzz"],"fizzy":"wizzy"}`,
 	patch:   `[{ "op": "move", "from": "/noexist", "path": "/fizzy" }]`,
-	wantErr: errors.New(`hujson: patch operation 0: value not found`),
+	wantErr: &PatchError{OpIndex: 0, Op: "move", Path: "/fizzy", From: "/noexist", Kind: PatchPathNotFound, Offset: 1, Line: 1, Column: 2, Err: errNotFound},
 }, {
 	in:      `{"fizz":["buzz","wuzz"],"fizzy":"wizzy"}`,
 	patch:   `[{ "op": "test", "path": "/noexist", "value": null }]`,
-	wantErr: errors.New(`hujson: patch operation 0: value not found`),
+	wantErr: &PatchError{OpIndex: 0, Op: "test", Path: "/noexist", Kind: PatchPathNotFound, Offset: 1, Line: 1, Column: 2, Err: errNotFound},
 }, {
 	in:      `{}`,
 	patch:   `[{`,
-	wantErr: fmt.Errorf(`hujson: line 1, column 3: %w`, fmt.Errorf("parsing value: %w", io.ErrUnexpectedEOF)),
+	wantErr: &SyntaxError{Offset: 2, Line: 1, Column: 3, Expected: "value", Err: fmt.Errorf("parsing value: %w", io.ErrUnexpectedEOF)},
 }, {
 	in:      `{}`,
 	patch:   `{}`,
-	wantErr: errors.New(`hujson: patch must be a JSON array`),
+	wantErr: &PatchError{OpIndex: -1, Kind: PatchInvalid, Offset: 0, Line: 1, Column: 1, Err: errors.New(`patch must be a JSON array`)},
 }, {
 	in:      `{}`,
 	patch:   `[[]]`,
-	wantErr: errors.New(`hujson: patch operation 0: must be a JSON object`),
+	wantErr: &PatchError{OpIndex: 0, Kind: PatchInvalid, Offset: 1, Line: 1, Column: 2, Err: errors.New(`must be a JSON object`)},
 }, {
 	in:      `{}`,
 	patch:   `[{"op":null}]`,
-	wantErr: errors.New(`hujson: patch operation 0: member "op" must be a JSON string`),
+	wantErr: &PatchError{OpIndex: 0, Kind: PatchInvalid, Offset: 1, Line: 1, Column: 2, Err: errors.New(`member "op" must be a JSON string`)},
 }, {
 	in:      `{}`,
 	patch:   `[{"op":"Move"}]`,
-	wantErr: errors.New(`hujson: patch operation 0: unknown operation "Move"`),
+	wantErr: &PatchError{OpIndex: 0, Kind: PatchInvalid, Offset: 1, Line: 1, Column: 2, Err: errors.New(`unknown operation "Move"`)},
 }, {
 	in:      `{}`,
 	patch:   `[{"op":"move","path":null}]`,
-	wantErr: errors.New(`hujson: patch operation 0: member "path" must be a JSON string`),
+	wantErr: &PatchError{OpIndex: 0, Op: "move", Kind: PatchInvalid, Offset: 1, Line: 1, Column: 2, Err: errors.New(`member "path" must be a JSON string`)},
 }, {
 	in:      `{}`,
 	patch:   `[{"op":"move","from":null}]`,
-	wantErr: errors.New(`hujson: patch operation 0: member "from" must be a JSON string`),
+	wantErr: &PatchError{OpIndex: 0, Op: "move", Kind: PatchInvalid, Offset: 1, Line: 1, Column: 2, Err: errors.New(`member "from" must be a JSON string`)},
 }, {
 	in:      `{}`,
 	patch:   `[{}]`,
-	wantErr: errors.New(`hujson: patch operation 0: missing required member "op"`),
+	wantErr: &PatchError{OpIndex: 0, Kind: PatchInvalid, Offset: 1, Line: 1, Column: 2, Err: errors.New(`missing required member "op"`)},
 }, {
 	in:      `{}`,
 	patch:   `[{"op":"move"}]`,
-	wantErr: errors.New(`hujson: patch operation 0: missing required member "path"`),
+	wantErr: &PatchError{OpIndex: 0, Op: "move", Kind: PatchInvalid, Offset: 1, Line: 1, Column: 2, Err: errors.New(`missing required member "path"`)},
 }, {
 	in:      `{}`,
 	patch:   `[{"op":"move","path":""}]`,
-	wantErr: errors.New(`hujson: patch operation 0: missing required member "from"`),
+	wantErr: &PatchError{OpIndex: 0, Op: "move", Kind: PatchInvalid, Offset: 1, Line: 1, Column: 2, Err: errors.New(`missing required member "from"`)},
 }, {
 	in:      `{}`,
 	patch:   `[{"op":"add","path":""}]`,
-	wantErr: errors.New(`hujson: patch operation 0: missing required member "value"`),
+	wantErr: &PatchError{OpIndex: 0, Op: "add", Kind: PatchInvalid, Offset: 1, Line: 1, Column: 2, Err: errors.New(`missing required member "value"`)},
 }, {
 	in:      `{"~1":0}`,
 	patch:   `[{"op":"test","path":""}]`,
-	wantErr: errors.New(`hujson: patch operation 0: missing required member "value"`),
+	wantErr: &PatchError{OpIndex: 0, Op: "test", Kind: PatchInvalid, Offset: 1, Line: 1, Column: 2, Err: errors.New(`missing required member "value"`)},
 }, {
 	in:      "{}",
 	patch:   `[{"op":"move","from":"","path":"z"}]`,
-	wantErr: errors.New(`hujson: patch operation 0: cannot move "" into "z"`),
+	wantErr: &PatchError{OpIndex: 0, Op: "move", Path: "z", Kind: PatchInvalidTarget, Offset: 1, Line: 1, Column: 2, Err: errors.New(`cannot move "" into "z"`)},
 }, {
 	in:      "{}",
 	patch:   `[{"op":"copy","from":"","path":"/noexist"}]`,
-	wantErr: errors.New(`hujson: patch operation 0: cannot copy "" into "/noexist"`),
+	wantErr: &PatchError{OpIndex: 0, Op: "copy", Path: "/noexist", Kind: PatchInvalidTarget, Offset: 1, Line: 1, Column: 2, Err: errors.New(`cannot copy "" into "/noexist"`)},
 }, {
 	in:      `"` + "\xff" + `"`,
 	patch:   `[{ "op": "test", "path": "", "value": "` + "\ufffd" + `" }]`,
-	wantErr: nil, // TODO(dsnet): Should fail due to invalid UTF-8.
+	wantErr: &PatchError{OpIndex: 0, Op: "test", Path: "", Kind: PatchTestFailed, Offset: 1, Line: 1, Column: 2, Err: errors.New(`values differ at ""`)},
 }, {
 	in:      `9223372036854775800`,
 	patch:   `[{ "op": "test", "path": "", "value": 9223372036854775801 }]`,
-	wantErr: nil, // TODO(dsnet): Should fail under precise integer equality.
+	wantErr: &PatchError{OpIndex: 0, Op: "test", Path: "", Kind: PatchTestFailed, Offset: 1, Line: 1, Column: 2, Err: errors.New(`values differ at ""`)},
 }, {
 	in:    `1e1000`,
 	patch: `[{ "op": "test", "path": "", "value": 1e1000 }]`,
-	// TODO(dsnet): Should pass under comparison of closest floating-point values.
-	wantErr: errors.New(`hujson: patch operation 0: values differ at ""`),
 }, {
 	in:      `{ "dupe": "foo", "dupe": "bar" }`,
 	patch:   `[{ "op": "test", "path": "", "value": { "dupe": "bar" } }]`,
@@ -519,3 +529,214 @@ func TestPatch(t *testing.T) {
 		})
 	}
 }
+
+var testdataMergePatch = []struct {
+	in    string
+	patch string
+	want  string
+}{
+	// Test cases from RFC 7386, Appendix A.
+	{in: `{"a":"b"}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+	{in: `{"a":"b"}`, patch: `{"b":"c"}`, want: `{"a":"b","b":"c"}`},
+	{in: `{"a":"b"}`, patch: `{"a":null}`, want: `{}`},
+	{in: `{"a":"b","b":"c"}`, patch: `{"a":null}`, want: `{"b":"c"}`},
+	{in: `{"a":["b"]}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+	{in: `{"a":"c"}`, patch: `{"a":["b"]}`, want: `{"a":["b"]}`},
+	{in: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, want: `{"a":{"b":"d"}}`},
+	{in: `{"a":[{"b":"c"}]}`, patch: `{"a":[1]}`, want: `{"a":[1]}`},
+	{in: `["a","b"]`, patch: `["c","d"]`, want: `["c","d"]`},
+	{in: `{"a":"b"}`, patch: `["c"]`, want: `["c"]`},
+	{in: `{"a":"foo"}`, patch: `null`, want: `null`},
+	{in: `{"a":"foo"}`, patch: `"bar"`, want: `"bar"`},
+	{in: `{"e":null}`, patch: `{"a":1}`, want: `{"e":null,"a":1}`},
+	{in: `[1,2]`, patch: `{"a":"b","c":null}`, want: `{"a":"b"}`},
+	{in: `{}`, patch: `{"a":{"bb":{"ccc":null}}}`, want: `{"a":{"bb":{}}}`},
+	{in: `{"a":"b"}`, patch: `{"a":{"b":null,"c":{"d":null}}}`, want: `{"a":{"c":{}}}`},
+	{
+		in: `{
+	// Comment1
+	"a": "b", // Comment2
+	// Comment3
+	"c": {
+		"d": "e", // Comment4
+		"f": "g", // Comment5
+	},
+	// Comment6
+	"h": "i", // Comment7
+}`,
+		patch: `{
+	// Comment8
+	"a": "z", // Comment9
+	"c": {
+		"f": null,
+		// Comment10
+		"j": "k", // Comment11
+	},
+	"h": null,
+	// Comment12
+	"l": [1, 2], // Comment13
+}`,
+		want: `{
+	// Comment8
+	"a": "z", // Comment9
+	// Comment3
+	"c": {
+		"d": "e", // Comment4
+		// Comment10
+		"j": "k", // Comment11
+	},
+	// Comment12
+	"l": [1, 2], // Comment13
+}`,
+	},
+}
+
+func TestMergePatch(t *testing.T) {
+	for _, tt := range testdataMergePatch {
+		t.Run("", func(t *testing.T) {
+			v, err := Parse([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("Parse error: %v", err)
+			}
+			if err := v.MergePatch([]byte(tt.patch)); err != nil {
+				t.Fatalf("MergePatch error: %v", err)
+			}
+			v.Format()
+			want, err := Format([]byte(tt.want))
+			if err != nil {
+				t.Fatalf("Format error: %v", err)
+			}
+			if diff := cmp.Diff(string(want), v.String()); diff != "" {
+				t.Errorf("MergePatch mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	v, err := Parse([]byte(`{}`))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if err := v.MergePatch([]byte(`{`)); err == nil {
+		t.Errorf("MergePatch error is nil, want non-nil")
+	}
+}
+
+func TestPatchAtomic(t *testing.T) {
+	mustParse := func(s string) Value {
+		v, err := Parse([]byte(s))
+		if err != nil {
+			t.Fatalf("Parse error: %v", err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		in    string
+		patch string
+	}{{
+		in:    `{"a": 1, "b": [1, 2, 3]}`,
+		patch: `[{"op": "remove", "path": "/a"}, {"op": "test", "path": "/b/0", "value": 2}]`,
+	}, {
+		in: `{
+	// Comment1
+	"a": 1, // Comment2
+	// Comment3
+	"b": [
+		1, // Comment4
+		// Comment5
+		2,
+		3, // Comment6
+	],
+	"c": {"d": "e"},
+}`,
+		patch: `[
+	{"op": "add", "path": "/b/1", "value": /* Comment7 */ 4 /* Comment8 */},
+	{"op": "move", "path": "/c/f", "from": "/a"},
+	{"op": "copy", "path": "/b/-", "from": "/c"},
+	{"op": "remove", "path": "/b/0"},
+	{"op": "replace", "path": "/c/d", "value": null},
+	{"op": "add", "path": "", "value": [1, 2, 3]},
+	{"op": "remove", "path": "/noexist"},
+]`,
+	}, {
+		in:    `[1, 2, 3]`,
+		patch: `[{"op": "add", "path": "/-", "value": 4}, {"op": "move", "path": "/1", "from": "/0"}, {"op": "test", "path": "/3", "value": 5}]`,
+	}}
+
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			v, want := mustParse(tt.in), mustParse(tt.in)
+			if err := want.Patch([]byte(tt.patch)); err == nil {
+				t.Fatalf("Patch error is nil, want non-nil")
+			} else if got := v.PatchWithOptions([]byte(tt.patch), PatchOptions{Atomic: true}); !reflect.DeepEqual(got, err) {
+				t.Errorf("PatchWithOptions error mismatch:\ngot  %v\nwant %v", got, err)
+			}
+			if got := v.String(); got != tt.in {
+				t.Errorf("PatchWithOptions did not restore value:\ngot:\n%s\n\nwant:\n%s", got, tt.in)
+			}
+		})
+	}
+
+	// A successful patch is applied identically to Patch.
+	for _, tt := range testdataPatch {
+		if tt.wantErr != nil {
+			continue
+		}
+		v1, v2 := mustParse(tt.in), mustParse(tt.in)
+		if err := v1.Patch([]byte(tt.patch)); err != nil {
+			t.Fatalf("Patch error: %v", err)
+		}
+		if err := v2.PatchWithOptions([]byte(tt.patch), PatchOptions{Atomic: true}); err != nil {
+			t.Fatalf("PatchWithOptions error: %v", err)
+		}
+		if got, want := v2.String(), v1.String(); got != want {
+			t.Errorf("PatchWithOptions mismatch:\ngot:\n%s\n\nwant:\n%s", got, want)
+		}
+	}
+}
+
+func TestPatchError(t *testing.T) {
+	in := `{"a": [1, 2], "b": "c"}`
+	tests := []struct {
+		patch   string
+		wantErr *PatchError
+	}{{
+		patch: `[
+	{"op": "test", "path": "/a/0", "value": 1},
+	{"op": "remove", "path": "/a/x"},
+]`,
+		wantErr: &PatchError{OpIndex: 1, Op: "remove", Path: "/a/x", Kind: PatchInvalidPointer, Offset: 48, Line: 3, Column: 2, Err: fmt.Errorf("%w: %s", errInvalidArrayIdx, "x")},
+	}, {
+		patch:   `[{"op": "add", "path": "/b/c", "value": 1}]`,
+		wantErr: &PatchError{OpIndex: 0, Op: "add", Path: "/b/c", Kind: PatchTypeMismatch, Offset: 1, Line: 1, Column: 2, Err: fmt.Errorf("invalid pointer: %w at %v", errIndexLiteral, "/b")},
+	}, {
+		patch:   `[{"op": "copy", "from": "a", "path": "/d"}]`,
+		wantErr: &PatchError{OpIndex: 0, Op: "copy", Path: "/d", From: "a", Kind: PatchInvalidPointer, Offset: 1, Line: 1, Column: 2, Err: fmt.Errorf("invalid pointer: %w", errMissingSlash)},
+	}, {
+		patch: `
+// Comment
+{"op": "test"}`,
+		wantErr: &PatchError{OpIndex: -1, Kind: PatchInvalid, Offset: 12, Line: 3, Column: 1, Err: errors.New("patch must be a JSON array")},
+	}}
+
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			v, err := Parse([]byte(in))
+			if err != nil {
+				t.Fatalf("Parse error: %v", err)
+			}
+			err = v.Patch([]byte(tt.patch))
+			var gotErr *PatchError
+			if !errors.As(err, &gotErr) {
+				t.Fatalf("Patch error = %v, want *PatchError", err)
+			}
+			if !reflect.DeepEqual(gotErr, tt.wantErr) {
+				t.Errorf("Patch error mismatch:\ngot  %#v\nwant %#v", gotErr, tt.wantErr)
+			}
+		})
+	}
+
+	if got, want := PatchTestFailed.String(), "test failed"; got != want {
+		t.Errorf("PatchTestFailed.String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,1132 @@
+// Copyright (c) 2021 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hujson
+
+import (
+	"fmt"
+	"iter"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Query evaluates a JSONPath expression (per RFC 9535) against the value.
+// It returns an iterator over each matched value along with
+// a JSON pointer (per RFC 6901) that locates it relative to v.
+// The iterator reports an error only for invalid expressions.
+//
+// All JSONPath selectors are supported (names, wildcards, indexes, slices,
+// and filter expressions), along with the descendant segment and
+// the standard function extensions: length, count, match, search, and value.
+// Object members are visited in the order they appear.
+// If an object has multiple members with the same name,
+// a name selector matches only the first (consistent with Find),
+// while a wildcard selector matches all of them.
+//
+// The yielded values point into v such that they may be mutated in place
+// while preserving surrounding comments. Mutations that add or remove
+// object members or array elements must not be performed during iteration.
+func (v *Value) Query(expr string) (iter.Seq2[string, *Value], error) {
+	q, err := parseQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	return func(yield func(string, *Value) bool) {
+		q.eval(v, v, nil, func(ptr []byte, v *Value) bool {
+			return yield(string(ptr), v)
+		})
+	}, nil
+}
+
+// jsonPath is a parsed JSONPath query,
+// which is either an absolute query (starting with '$')
+// or a relative query (starting with '@') within a filter.
+type jsonPath struct {
+	relative bool
+	segments []querySegment
+}
+
+type querySegment struct {
+	descendant bool // whether this is a descendant segment (i.e., "..")
+	selectors  []querySelector
+}
+
+// querySelector selects zero or more children of a value.
+type querySelector interface {
+	// selectFrom calls yield for each child of v selected, where ptr is
+	// the JSON pointer to v. It reports false if yield returns false.
+	selectFrom(root, v *Value, ptr []byte, yield func([]byte, *Value) bool) bool
+}
+
+type (
+	nameSelector     struct{ name string }
+	wildcardSelector struct{}
+	indexSelector    struct{ index int64 }
+	sliceSelector    struct {
+		start, end *int64
+		step       int64
+	}
+	filterSelector struct{ expr logicalExpr }
+)
+
+// isSingular reports whether the query produces at most one value.
+func (q *jsonPath) isSingular() bool {
+	for _, seg := range q.segments {
+		if seg.descendant || len(seg.selectors) != 1 {
+			return false
+		}
+		switch seg.selectors[0].(type) {
+		case nameSelector, indexSelector:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// eval calls yield for each value produced by the query,
+// where root is the value for '$' and v is the value for '@'.
+func (q *jsonPath) eval(root, v *Value, ptr []byte, yield func([]byte, *Value) bool) bool {
+	if !q.relative {
+		v = root
+	}
+	return evalSegments(q.segments, root, v, ptr, yield)
+}
+
+func evalSegments(segs []querySegment, root, v *Value, ptr []byte, yield func([]byte, *Value) bool) bool {
+	if len(segs) == 0 {
+		return yield(ptr, v)
+	}
+	next := func(ptr []byte, v *Value) bool {
+		return evalSegments(segs[1:], root, v, ptr, yield)
+	}
+	if segs[0].descendant {
+		return visitDescendants(v, ptr, func(ptr []byte, v *Value) bool {
+			return segs[0].selectFrom(root, v, ptr, next)
+		})
+	}
+	return segs[0].selectFrom(root, v, ptr, next)
+}
+
+func (seg querySegment) selectFrom(root, v *Value, ptr []byte, yield func([]byte, *Value) bool) bool {
+	for _, sel := range seg.selectors {
+		if !sel.selectFrom(root, v, ptr, yield) {
+			return false
+		}
+	}
+	return true
+}
+
+// visitDescendants calls yield for v and all of its descendants,
+// where each value is visited before its descendants.
+func visitDescendants(v *Value, ptr []byte, yield func([]byte, *Value) bool) bool {
+	for ptr, v := range v.walkPointers(ptr) {
+		if !yield(ptr, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (sel nameSelector) selectFrom(_, v *Value, ptr []byte, yield func([]byte, *Value) bool) bool {
+	if obj, ok := v.Value.(*Object); ok {
+		for i, m := range obj.Members {
+			if m.Name.Value.(Literal).equalString(sel.name) {
+				return yield(appendPointerToken(ptr, sel.name), &obj.Members[i].Value)
+			}
+		}
+	}
+	return true
+}
+
+func (wildcardSelector) selectFrom(_, v *Value, ptr []byte, yield func([]byte, *Value) bool) bool {
+	switch comp := v.Value.(type) {
+	case *Object:
+		for i, m := range comp.Members {
+			if !yield(appendPointerToken(ptr, m.Name.Value.(Literal).String()), &comp.Members[i].Value) {
+				return false
+			}
+		}
+	case *Array:
+		for i := range comp.Elements {
+			if !yield(appendPointerIndex(ptr, i), &comp.Elements[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (sel indexSelector) selectFrom(_, v *Value, ptr []byte, yield func([]byte, *Value) bool) bool {
+	if arr, ok := v.Value.(*Array); ok {
+		i := sel.index
+		if i < 0 {
+			i += int64(len(arr.Elements))
+		}
+		if 0 <= i && i < int64(len(arr.Elements)) {
+			return yield(appendPointerIndex(ptr, int(i)), &arr.Elements[i])
+		}
+	}
+	return true
+}
+
+func (sel sliceSelector) selectFrom(_, v *Value, ptr []byte, yield func([]byte, *Value) bool) bool {
+	arr, ok := v.Value.(*Array)
+	if !ok || sel.step == 0 {
+		return true
+	}
+
+	// Compute the bounds according to RFC 9535, section 2.3.4.2.2.
+	n := int64(len(arr.Elements))
+	normalize := func(i *int64, defaultIndex int64) int64 {
+		switch {
+		case i == nil:
+			return defaultIndex
+		case *i < 0:
+			return n + *i
+		default:
+			return *i
+		}
+	}
+	if sel.step > 0 {
+		lower := min(max(normalize(sel.start, 0), 0), n)
+		upper := min(max(normalize(sel.end, n), 0), n)
+		for i := lower; i < upper; i += sel.step {
+			if !yield(appendPointerIndex(ptr, int(i)), &arr.Elements[i]) {
+				return false
+			}
+		}
+	} else {
+		upper := min(max(normalize(sel.start, n-1), -1), n-1)
+		lower := min(max(normalize(sel.end, -n-1), -1), n-1)
+		for i := upper; lower < i; i += sel.step {
+			if !yield(appendPointerIndex(ptr, int(i)), &arr.Elements[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (sel filterSelector) selectFrom(root, v *Value, ptr []byte, yield func([]byte, *Value) bool) bool {
+	return wildcardSelector{}.selectFrom(nil, v, ptr, func(ptr []byte, v *Value) bool {
+		if sel.expr.test(root, v) {
+			return yield(ptr, v)
+		}
+		return true
+	})
+}
+
+// appendPointerIndex appends a JSON pointer reference token for an array index.
+func appendPointerIndex(b []byte, i int) []byte {
+	return strconv.AppendInt(append(b, '/'), int64(i), 10)
+}
+
+// logicalExpr is a filter expression that produces a boolean result,
+// where root is the value for '$' and v is the value for '@'.
+type logicalExpr interface {
+	test(root, v *Value) bool
+}
+
+type (
+	orExpr      []logicalExpr
+	andExpr     []logicalExpr
+	notExpr     struct{ expr logicalExpr }
+	existExpr   struct{ query *jsonPath }
+	compareExpr struct {
+		op          string // "==" | "!=" | "<" | "<=" | ">" | ">="
+		left, right valueExpr
+	}
+)
+
+func (x orExpr) test(root, v *Value) bool {
+	for _, x := range x {
+		if x.test(root, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (x andExpr) test(root, v *Value) bool {
+	for _, x := range x {
+		if !x.test(root, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (x notExpr) test(root, v *Value) bool {
+	return !x.expr.test(root, v)
+}
+
+func (x existExpr) test(root, v *Value) bool {
+	var found bool
+	x.query.eval(root, v, nil, func([]byte, *Value) bool {
+		found = true
+		return false
+	})
+	return found
+}
+
+func (x compareExpr) test(root, v *Value) bool {
+	left := x.left.value(root, v)
+	right := x.right.value(root, v)
+	switch x.op {
+	case "==":
+		return queryEqual(left, right)
+	case "!=":
+		return !queryEqual(left, right)
+	case "<":
+		return queryLess(left, right)
+	case "<=":
+		return queryLess(left, right) || queryEqual(left, right)
+	case ">":
+		return queryLess(right, left)
+	case ">=":
+		return queryLess(right, left) || queryEqual(left, right)
+	}
+	return false
+}
+
+// valueExpr is a filter expression that produces a single value,
+// where nil represents the absence of a value (i.e., "Nothing").
+type valueExpr interface {
+	value(root, v *Value) *Value
+}
+
+type (
+	literalValue  struct{ v *Value }
+	singularQuery struct{ query *jsonPath }
+)
+
+func (x literalValue) value(root, v *Value) *Value {
+	return x.v
+}
+
+func (x singularQuery) value(root, v *Value) (out *Value) {
+	x.query.eval(root, v, nil, func(_ []byte, v *Value) bool {
+		out = v
+		return false
+	})
+	return out
+}
+
+// queryEqual reports whether x and y are equal according to
+// the comparison rules of RFC 9535, section 2.3.5.2.2.
+func queryEqual(x, y *Value) bool {
+	if x == nil || y == nil {
+		return x == nil && y == nil
+	}
+	return queryEqualTrimmed(x.Value, y.Value)
+}
+
+func queryEqualTrimmed(x, y ValueTrimmed) bool {
+	if x.Kind() != y.Kind() {
+		return false
+	}
+	switch x := x.(type) {
+	case Literal:
+		return equalLiteral(x, y.(Literal))
+	case *Object:
+		y := y.(*Object)
+		if len(x.Members) != len(y.Members) {
+			return false
+		}
+		// Names are compared exactly, such that names differing only
+		// in unpaired surrogates or invalid UTF-8 are distinct.
+		indexes := make(map[string]int, len(y.Members))
+		for i, m := range slices.Backward(y.Members) {
+			indexes[m.Name.Value.(Literal).exactKey()] = i
+		}
+		for _, mx := range x.Members {
+			i, ok := indexes[mx.Name.Value.(Literal).exactKey()]
+			if !ok || !queryEqualTrimmed(mx.Value.Value, y.Members[i].Value.Value) {
+				return false
+			}
+		}
+		return true
+	case *Array:
+		y := y.(*Array)
+		if len(x.Elements) != len(y.Elements) {
+			return false
+		}
+		for i := range x.Elements {
+			if !queryEqualTrimmed(x.Elements[i].Value, y.Elements[i].Value) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// queryLess reports whether x is less than y according to
+// the comparison rules of RFC 9535, section 2.3.5.2.2.
+// Only numbers and strings are ordered.
+// Numbers are compared exactly and strings are compared by code point.
+func queryLess(x, y *Value) bool {
+	if x == nil || y == nil {
+		return false
+	}
+	lx, okx := x.Value.(Literal)
+	ly, oky := y.Value.(Literal)
+	if !okx || !oky || lx.Kind() != ly.Kind() {
+		return false
+	}
+	switch lx.Kind() {
+	case '0':
+		rx, okx := lx.Rat()
+		ry, oky := ly.Rat()
+		return okx && oky && rx.Cmp(ry) < 0
+	case '"':
+		return slices.Compare(lx.decodeExact(), ly.decodeExact()) < 0
+	}
+	return false
+}
+
+// queryType is the type of a function parameter or result
+// (see RFC 9535, section 2.4.1).
+type queryType int
+
+const (
+	valueType queryType = iota
+	logicalType
+	nodesType
+)
+
+type queryFunction struct {
+	params []queryType
+	result queryType
+}
+
+// queryFunctions are the function extensions defined by RFC 9535, section 2.4.
+var queryFunctions = map[string]queryFunction{
+	"length": {[]queryType{valueType}, valueType},
+	"count":  {[]queryType{nodesType}, valueType},
+	"match":  {[]queryType{valueType, valueType}, logicalType},
+	"search": {[]queryType{valueType, valueType}, logicalType},
+	"value":  {[]queryType{nodesType}, valueType},
+}
+
+// funcExpr is a function extension call.
+// Arguments of valueType are valueExpr, while
+// arguments of nodesType are *jsonPath.
+type funcExpr struct {
+	name string
+	args []any
+	re   *regexp.Regexp // precompiled regular expression for match and search
+}
+
+func (x funcExpr) value(root, v *Value) *Value {
+	switch x.name {
+	case "length":
+		arg := x.args[0].(valueExpr).value(root, v)
+		if arg == nil {
+			return nil
+		}
+		switch arg := arg.Value.(type) {
+		case Literal:
+			if arg.Kind() == '"' {
+				return &Value{Value: Int(int64(utf8.RuneCountInString(arg.String())))}
+			}
+		case *Object:
+			return &Value{Value: Int(int64(len(arg.Members)))}
+		case *Array:
+			return &Value{Value: Int(int64(len(arg.Elements)))}
+		}
+		return nil
+	case "count":
+		var n int64
+		x.args[0].(*jsonPath).eval(root, v, nil, func([]byte, *Value) bool {
+			n++
+			return true
+		})
+		return &Value{Value: Int(n)}
+	case "value":
+		var out *Value
+		var n int
+		x.args[0].(*jsonPath).eval(root, v, nil, func(_ []byte, v *Value) bool {
+			out = v
+			n++
+			return n < 2
+		})
+		if n != 1 {
+			return nil
+		}
+		return out
+	}
+	return nil
+}
+
+func (x funcExpr) test(root, v *Value) bool {
+	switch x.name {
+	case "match", "search":
+		s := x.args[0].(valueExpr).value(root, v)
+		p := x.args[1].(valueExpr).value(root, v)
+		if s == nil || p == nil || s.Value.Kind() != '"' || p.Value.Kind() != '"' {
+			return false
+		}
+		re := x.re
+		if re == nil {
+			var err error
+			re, err = compileIRegexp(p.Value.(Literal).String(), x.name == "match")
+			if err != nil {
+				return false
+			}
+		}
+		return re.MatchString(s.Value.(Literal).String())
+	}
+	return false
+}
+
+// compileIRegexp compiles an I-Regexp (per RFC 9485) as a Go regular expression.
+// If anchored, the expression must match the entire input.
+func compileIRegexp(expr string, anchored bool) (*regexp.Regexp, error) {
+	// The '.' character in I-Regexp does not match '\n' or '\r',
+	// while Go only excludes '\n'.
+	var sb strings.Builder
+	var inClass bool
+	for i := 0; i < len(expr); i++ {
+		switch c := expr[i]; {
+		case c == '\\' && i+1 < len(expr):
+			sb.WriteString(expr[i : i+2])
+			i++
+		case c == '[':
+			inClass = true
+			sb.WriteByte(c)
+		case c == ']':
+			inClass = false
+			sb.WriteByte(c)
+		case c == '.' && !inClass:
+			sb.WriteString(`[^\n\r]`)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	if anchored {
+		return regexp.Compile(`^(?:` + sb.String() + `)$`)
+	}
+	return regexp.Compile(sb.String())
+}
+
+// parseQuery parses a JSONPath expression.
+func parseQuery(expr string) (*jsonPath, error) {
+	p := queryParser{s: expr}
+	if !p.consume("$") {
+		return nil, p.errorf("query must start with '$'")
+	}
+	q, err := p.parseSegments()
+	if err != nil {
+		return nil, err
+	}
+	if p.n < len(p.s) {
+		return nil, p.errorf("unexpected character %q", p.s[p.n])
+	}
+	return q, nil
+}
+
+type queryParser struct {
+	s string
+	n int
+}
+
+func (p *queryParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("hujson: invalid JSONPath query at offset %d: %s", p.n, fmt.Sprintf(format, args...))
+}
+
+func (p *queryParser) peek() byte {
+	if p.n < len(p.s) {
+		return p.s[p.n]
+	}
+	return 0
+}
+
+func (p *queryParser) consume(s string) bool {
+	if strings.HasPrefix(p.s[p.n:], s) {
+		p.n += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) skipBlank() {
+	for p.n < len(p.s) && strings.IndexByte(" \t\n\r", p.s[p.n]) >= 0 {
+		p.n++
+	}
+}
+
+// parseSegments parses the segments following a '$' or '@' identifier.
+func (p *queryParser) parseSegments() (*jsonPath, error) {
+	var q jsonPath
+	for {
+		n0 := p.n
+		p.skipBlank()
+		var seg querySegment
+		switch {
+		case p.consume(".."):
+			seg.descendant = true
+			switch {
+			case p.peek() == '[':
+				sels, err := p.parseBracketed()
+				if err != nil {
+					return nil, err
+				}
+				seg.selectors = sels
+			case p.consume("*"):
+				seg.selectors = []querySelector{wildcardSelector{}}
+			default:
+				name, err := p.parseMemberName()
+				if err != nil {
+					return nil, err
+				}
+				seg.selectors = []querySelector{nameSelector{name}}
+			}
+		case p.consume("."):
+			if p.consume("*") {
+				seg.selectors = []querySelector{wildcardSelector{}}
+			} else {
+				name, err := p.parseMemberName()
+				if err != nil {
+					return nil, err
+				}
+				seg.selectors = []querySelector{nameSelector{name}}
+			}
+		case p.peek() == '[':
+			sels, err := p.parseBracketed()
+			if err != nil {
+				return nil, err
+			}
+			seg.selectors = sels
+		default:
+			p.n = n0 // do not consume trailing whitespace
+			return &q, nil
+		}
+		q.segments = append(q.segments, seg)
+	}
+}
+
+// parseMemberName parses a member-name-shorthand.
+func (p *queryParser) parseMemberName() (string, error) {
+	n0 := p.n
+	for p.n < len(p.s) {
+		r, size := utf8.DecodeRuneInString(p.s[p.n:])
+		isFirst := r == '_' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') ||
+			(r >= 0x80 && r != utf8.RuneError && (r <= 0xd7ff || r >= 0xe000))
+		isDigit := '0' <= r && r <= '9'
+		if !isFirst && !(isDigit && p.n > n0) {
+			break
+		}
+		p.n += size
+	}
+	if p.n == n0 {
+		return "", p.errorf("invalid member name")
+	}
+	return p.s[n0:p.n], nil
+}
+
+// parseBracketed parses a bracketed selection.
+func (p *queryParser) parseBracketed() ([]querySelector, error) {
+	p.consume("[")
+	var sels []querySelector
+	for {
+		p.skipBlank()
+		sel, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, sel)
+		p.skipBlank()
+		switch {
+		case p.consume(","):
+		case p.consume("]"):
+			return sels, nil
+		default:
+			return nil, p.errorf("expected ',' or ']'")
+		}
+	}
+}
+
+func (p *queryParser) parseSelector() (querySelector, error) {
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		name, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return nameSelector{name}, nil
+	case p.consume("*"):
+		return wildcardSelector{}, nil
+	case p.consume("?"):
+		p.skipBlank()
+		expr, err := p.parseLogicalOr()
+		if err != nil {
+			return nil, err
+		}
+		return filterSelector{expr}, nil
+	case c == '-' || c == ':' || ('0' <= c && c <= '9'):
+		var start, end *int64
+		if c != ':' {
+			i, err := p.parseInt()
+			if err != nil {
+				return nil, err
+			}
+			p.skipBlank()
+			if p.peek() != ':' {
+				return indexSelector{i}, nil
+			}
+			start = &i
+		}
+		sel := sliceSelector{start: start, step: 1}
+		p.consume(":")
+		p.skipBlank()
+		if c := p.peek(); c == '-' || ('0' <= c && c <= '9') {
+			i, err := p.parseInt()
+			if err != nil {
+				return nil, err
+			}
+			end = &i
+			p.skipBlank()
+		}
+		sel.end = end
+		if p.consume(":") {
+			p.skipBlank()
+			if c := p.peek(); c == '-' || ('0' <= c && c <= '9') {
+				i, err := p.parseInt()
+				if err != nil {
+					return nil, err
+				}
+				sel.step = i
+			}
+		}
+		return sel, nil
+	default:
+		return nil, p.errorf("invalid selector")
+	}
+}
+
+// parseInt parses an integer within the range of I-JSON (per RFC 7493).
+func (p *queryParser) parseInt() (int64, error) {
+	n0 := p.n
+	p.consume("-")
+	if p.consume("0") {
+		if p.n-n0 > 1 {
+			p.n = n0
+			return 0, p.errorf("invalid integer \"-0\"")
+		}
+	} else {
+		for p.n < len(p.s) && '0' <= p.s[p.n] && p.s[p.n] <= '9' {
+			p.n++
+		}
+	}
+	s := p.s[n0:p.n]
+	i, err := strconv.ParseInt(s, 10, 64)
+	const maxSafeInt = 1<<53 - 1
+	if err != nil || i < -maxSafeInt || i > maxSafeInt {
+		p.n = n0
+		return 0, p.errorf("invalid integer %q", s)
+	}
+	return i, nil
+}
+
+// parseString parses a single-quoted or double-quoted string literal.
+func (p *queryParser) parseString() (string, error) {
+	n0 := p.n
+	quote := p.s[p.n]
+	p.n++
+	var sb strings.Builder
+	for {
+		if p.n >= len(p.s) {
+			p.n = n0
+			return "", p.errorf("unterminated string")
+		}
+		switch c := p.s[p.n]; {
+		case c == quote:
+			p.n++
+			return sb.String(), nil
+		case c < ' ':
+			return "", p.errorf("invalid control character in string")
+		case c == '\\':
+			p.n++
+			switch c := p.peek(); c {
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case '/', '\\', quote:
+				sb.WriteByte(c)
+			case 'u':
+				r, err := p.parseUnicodeEscape()
+				if err != nil {
+					return "", err
+				}
+				sb.WriteRune(r)
+				continue
+			default:
+				return "", p.errorf("invalid escape sequence in string")
+			}
+			p.n++
+		default:
+			r, size := utf8.DecodeRuneInString(p.s[p.n:])
+			if r == utf8.RuneError && size == 1 {
+				return "", p.errorf("invalid UTF-8 in string")
+			}
+			sb.WriteString(p.s[p.n : p.n+size])
+			p.n += size
+		}
+	}
+}
+
+// parseUnicodeEscape parses a "uXXXX" escape (after the backslash),
+// including a subsequent low surrogate escape if necessary.
+func (p *queryParser) parseUnicodeEscape() (rune, error) {
+	parseHex := func() (rune, bool) {
+		if p.n+5 > len(p.s) || p.s[p.n] != 'u' {
+			return 0, false
+		}
+		r, err := strconv.ParseUint(p.s[p.n+1:p.n+5], 16, 16)
+		if err != nil {
+			return 0, false
+		}
+		p.n += 5
+		return rune(r), true
+	}
+	r, ok := parseHex()
+	switch {
+	case !ok:
+		return 0, p.errorf("invalid unicode escape in string")
+	case 0xdc00 <= r && r <= 0xdfff:
+		return 0, p.errorf("invalid unpaired surrogate in string")
+	case 0xd800 <= r && r <= 0xdbff:
+		if !p.consume(`\`) {
+			return 0, p.errorf("invalid unpaired surrogate in string")
+		}
+		r2, ok := parseHex()
+		if !ok || !(0xdc00 <= r2 && r2 <= 0xdfff) {
+			return 0, p.errorf("invalid unpaired surrogate in string")
+		}
+		r = 0x10000 + (r-0xd800)<<10 + (r2 - 0xdc00)
+	}
+	return r, nil
+}
+
+func (p *queryParser) parseLogicalOr() (logicalExpr, error) {
+	var exprs orExpr
+	for {
+		expr, err := p.parseLogicalAnd()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+		n0 := p.n
+		p.skipBlank()
+		if !p.consume("||") {
+			p.n = n0
+			break
+		}
+		p.skipBlank()
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return exprs, nil
+}
+
+func (p *queryParser) parseLogicalAnd() (logicalExpr, error) {
+	var exprs andExpr
+	for {
+		expr, err := p.parseBasic()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+		n0 := p.n
+		p.skipBlank()
+		if !p.consume("&&") {
+			p.n = n0
+			break
+		}
+		p.skipBlank()
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return exprs, nil
+}
+
+// parseBasic parses a parenthesized, comparison, or test expression.
+func (p *queryParser) parseBasic() (logicalExpr, error) {
+	if p.consume("!") {
+		p.skipBlank()
+		if p.peek() == '(' {
+			expr, err := p.parseParen()
+			if err != nil {
+				return nil, err
+			}
+			return notExpr{expr}, nil
+		}
+		if p.peek() != '@' && p.peek() != '$' && !p.atFunction() {
+			return nil, p.errorf("expected parenthesized or test expression after '!'")
+		}
+		expr, err := p.parseBasic()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := expr.(compareExpr); ok {
+			return nil, p.errorf("comparison must be parenthesized to be negated")
+		}
+		return notExpr{expr}, nil
+	}
+	if p.peek() == '(' {
+		return p.parseParen()
+	}
+
+	// Parse either side of a comparison or a test expression.
+	var left valueExpr
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		q, err := p.parseFilterQuery()
+		if err != nil {
+			return nil, err
+		}
+		if !p.atComparisonOp() {
+			return existExpr{q}, nil
+		}
+		if !q.isSingular() {
+			return nil, p.errorf("non-singular query is not comparable")
+		}
+		left = singularQuery{q}
+	case p.atFunction():
+		f, result, err := p.parseFunction()
+		if err != nil {
+			return nil, err
+		}
+		if !p.atComparisonOp() {
+			if result != logicalType {
+				return nil, p.errorf("function %s() result is not a logical value", f.name)
+			}
+			return f, nil
+		}
+		if result != valueType {
+			return nil, p.errorf("function %s() result is not comparable", f.name)
+		}
+		left = f
+	default:
+		lit, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		left = lit
+	}
+
+	p.skipBlank()
+	var op string
+	for _, s := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(s) {
+			op = s
+			break
+		}
+	}
+	if op == "" {
+		return nil, p.errorf("expected comparison operator")
+	}
+	p.skipBlank()
+	right, err := p.parseComparable()
+	if err != nil {
+		return nil, err
+	}
+	return compareExpr{op, left, right}, nil
+}
+
+// parseParen parses a parenthesized logical expression.
+func (p *queryParser) parseParen() (logicalExpr, error) {
+	p.consume("(")
+	p.skipBlank()
+	expr, err := p.parseLogicalOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if !p.consume(")") {
+		return nil, p.errorf("expected ')'")
+	}
+	return expr, nil
+}
+
+// atComparisonOp reports whether a comparison operator follows
+// any whitespace without consuming anything.
+func (p *queryParser) atComparisonOp() bool {
+	n0 := p.n
+	p.skipBlank()
+	defer func() { p.n = n0 }()
+	c := p.peek()
+	return c == '<' || c == '>' || p.consume("==") || p.consume("!=")
+}
+
+// atFunction reports whether a function name followed by '(' is next.
+func (p *queryParser) atFunction() bool {
+	i := p.n
+	if i >= len(p.s) || !('a' <= p.s[i] && p.s[i] <= 'z') {
+		return false
+	}
+	for i < len(p.s) && (('a' <= p.s[i] && p.s[i] <= 'z') || ('0' <= p.s[i] && p.s[i] <= '9') || p.s[i] == '_') {
+		i++
+	}
+	return i < len(p.s) && p.s[i] == '('
+}
+
+// parseFilterQuery parses a relative query (starting with '@')
+// or an absolute query (starting with '$').
+func (p *queryParser) parseFilterQuery() (*jsonPath, error) {
+	relative := p.consume("@")
+	if !relative && !p.consume("$") {
+		return nil, p.errorf("expected '@' or '$'")
+	}
+	q, err := p.parseSegments()
+	if err != nil {
+		return nil, err
+	}
+	q.relative = relative
+	return q, nil
+}
+
+// parseComparable parses a literal, singular query, or
+// function expression that produces a value.
+func (p *queryParser) parseComparable() (valueExpr, error) {
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		q, err := p.parseFilterQuery()
+		if err != nil {
+			return nil, err
+		}
+		if !q.isSingular() {
+			return nil, p.errorf("non-singular query is not comparable")
+		}
+		return singularQuery{q}, nil
+	case p.atFunction():
+		f, result, err := p.parseFunction()
+		if err != nil {
+			return nil, err
+		}
+		if result != valueType {
+			return nil, p.errorf("function %s() result is not comparable", f.name)
+		}
+		return f, nil
+	default:
+		return p.parseLiteral()
+	}
+}
+
+// parseLiteral parses a number, string, true, false, or null literal.
+func (p *queryParser) parseLiteral() (valueExpr, error) {
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return literalValue{&Value{Value: String(s)}}, nil
+	case c == '-' || ('0' <= c && c <= '9'):
+		n0 := p.n
+		p.consume("-")
+		if !p.consume("0") {
+			p.consumeDigits()
+		}
+		if p.consume(".") && !p.consumeDigits() {
+			return nil, p.errorf("invalid number")
+		}
+		if p.consume("e") || p.consume("E") {
+			_ = p.consume("+") || p.consume("-")
+			if !p.consumeDigits() {
+				return nil, p.errorf("invalid number")
+			}
+		}
+		lit := Literal(p.s[n0:p.n])
+		if !lit.IsValid() {
+			p.n = n0
+			return nil, p.errorf("invalid number")
+		}
+		if f := lit.Float(); math.IsInf(f, 0) {
+			p.n = n0
+			return nil, p.errorf("number out of range")
+		}
+		return literalValue{&Value{Value: lit}}, nil
+	}
+	for _, s := range []string{"true", "false", "null"} {
+		if p.consume(s) {
+			return literalValue{&Value{Value: Literal(s)}}, nil
+		}
+	}
+	return nil, p.errorf("expected literal, query, or function expression")
+}
+
+// consumeDigits consumes one or more digits and reports whether any were found.
+func (p *queryParser) consumeDigits() bool {
+	n0 := p.n
+	for p.n < len(p.s) && '0' <= p.s[p.n] && p.s[p.n] <= '9' {
+		p.n++
+	}
+	return p.n > n0
+}
+
+// parseFunction parses a function expression and
+// checks that the arguments are well-typed.
+func (p *queryParser) parseFunction() (funcExpr, queryType, error) {
+	i := strings.IndexByte(p.s[p.n:], '(')
+	name := p.s[p.n : p.n+i]
+	fn, ok := queryFunctions[name]
+	if !ok {
+		return funcExpr{}, 0, p.errorf("unknown function %s()", name)
+	}
+	p.n += i + len("(")
+	f := funcExpr{name: name}
+	for j, param := range fn.params {
+		p.skipBlank()
+		if j > 0 && !p.consume(",") {
+			return funcExpr{}, 0, p.errorf("function %s() expects %d arguments", name, len(fn.params))
+		}
+		p.skipBlank()
+		switch param {
+		case valueType:
+			arg, err := p.parseComparable()
+			if err != nil {
+				return funcExpr{}, 0, err
+			}
+			f.args = append(f.args, arg)
+		case nodesType:
+			if c := p.peek(); c != '@' && c != '$' {
+				return funcExpr{}, 0, p.errorf("function %s() expects a query argument", name)
+			}
+			q, err := p.parseFilterQuery()
+			if err != nil {
+				return funcExpr{}, 0, err
+			}
+			f.args = append(f.args, q)
+		}
+	}
+	p.skipBlank()
+	if !p.consume(")") {
+		return funcExpr{}, 0, p.errorf("function %s() expects %d arguments", name, len(fn.params))
+	}
+
+	// Precompile the regular expression if it is a valid literal.
+	// Invalid regular expressions cause the function to produce false.
+	if name == "match" || name == "search" {
+		if lit, ok := f.args[1].(literalValue); ok && lit.v.Value.Kind() == '"' {
+			f.re, _ = compileIRegexp(lit.v.Value.(Literal).String(), name == "match")
+		}
+	}
+	return f, fn.result, nil
+}
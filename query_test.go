@@ -0,0 +1,289 @@
+// Copyright (c) 2021 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hujson
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// queryInput is the example from RFC 9535, section 1.5.
+const queryInput = `{
+	"store": {
+		"book": [
+			{"category": "reference", "author": "Nigel Rees", "title": "Sayings of the Century", "price": 8.95},
+			{"category": "fiction", "author": "Evelyn Waugh", "title": "Sword of Honour", "price": 12.99},
+			{"category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553-21311-3", "price": 8.99},
+			{"category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord of the Rings", "isbn": "0-395-19395-8", "price": 22.99},
+		],
+		// Comment
+		"bicycle": {"color": "red", "price": 399},
+	},
+}`
+
+var testdataQuery = []struct {
+	in      string
+	query   string
+	want    []string // pointer and packed value pairs
+	wantErr string
+}{{
+	in:    queryInput,
+	query: `$.store.book[*].author`,
+	want: []string{
+		`/store/book/0/author`, `"Nigel Rees"`,
+		`/store/book/1/author`, `"Evelyn Waugh"`,
+		`/store/book/2/author`, `"Herman Melville"`,
+		`/store/book/3/author`, `"J. R. R. Tolkien"`,
+	},
+}, {
+	in:    queryInput,
+	query: `$..author`,
+	want: []string{
+		`/store/book/0/author`, `"Nigel Rees"`,
+		`/store/book/1/author`, `"Evelyn Waugh"`,
+		`/store/book/2/author`, `"Herman Melville"`,
+		`/store/book/3/author`, `"J. R. R. Tolkien"`,
+	},
+}, {
+	in:    queryInput,
+	query: `$.store..price`,
+	want: []string{
+		`/store/book/0/price`, `8.95`,
+		`/store/book/1/price`, `12.99`,
+		`/store/book/2/price`, `8.99`,
+		`/store/book/3/price`, `22.99`,
+		`/store/bicycle/price`, `399`,
+	},
+}, {
+	in:    queryInput,
+	query: `$..book[2].title`,
+	want:  []string{`/store/book/2/title`, `"Moby Dick"`},
+}, {
+	in:    queryInput,
+	query: `$..book[-1].title`,
+	want:  []string{`/store/book/3/title`, `"The Lord of the Rings"`},
+}, {
+	in:    queryInput,
+	query: `$..book[0,1].title`,
+	want: []string{
+		`/store/book/0/title`, `"Sayings of the Century"`,
+		`/store/book/1/title`, `"Sword of Honour"`,
+	},
+}, {
+	in:    queryInput,
+	query: `$..book[:2].title`,
+	want: []string{
+		`/store/book/0/title`, `"Sayings of the Century"`,
+		`/store/book/1/title`, `"Sword of Honour"`,
+	},
+}, {
+	in:    queryInput,
+	query: `$..book[?@.isbn].title`,
+	want: []string{
+		`/store/book/2/title`, `"Moby Dick"`,
+		`/store/book/3/title`, `"The Lord of the Rings"`,
+	},
+}, {
+	in:    queryInput,
+	query: `$..book[?@.price<10].title`,
+	want: []string{
+		`/store/book/0/title`, `"Sayings of the Century"`,
+		`/store/book/2/title`, `"Moby Dick"`,
+	},
+}, {
+	in:      queryInput,
+	query:   `$..book[?@.price < $.store.bicycle.price / 40].title`,
+	wantErr: `hujson: invalid JSONPath query at offset 41: expected ',' or ']'`,
+}, {
+	in:    queryInput,
+	query: `$["store"]['bicycle'].*`,
+	want: []string{
+		`/store/bicycle/color`, `"red"`,
+		`/store/bicycle/price`, `399`,
+	},
+}, {
+	in:    `{"o": {"j": 1, "k": 2}, "a": [5, 3, [{"j": 4}, {"k": 6}]]}`,
+	query: `$..[*]`,
+	want: []string{
+		`/o`, `{"j": 1, "k": 2}`,
+		`/a`, `[5, 3, [{"j": 4}, {"k": 6}]]`,
+		`/o/j`, `1`,
+		`/o/k`, `2`,
+		`/a/0`, `5`,
+		`/a/1`, `3`,
+		`/a/2`, `[{"j": 4}, {"k": 6}]`,
+		`/a/2/0`, `{"j": 4}`,
+		`/a/2/1`, `{"k": 6}`,
+		`/a/2/0/j`, `4`,
+		`/a/2/1/k`, `6`,
+	},
+}, {
+	in:    `["a", "b", "c", "d", "e", "f", "g"]`,
+	query: `$[1:5:2]`,
+	want:  []string{`/1`, `"b"`, `/3`, `"d"`},
+}, {
+	in:    `["a", "b", "c", "d", "e", "f", "g"]`,
+	query: `$[5:1:-2]`,
+	want:  []string{`/5`, `"f"`, `/3`, `"d"`},
+}, {
+	in:    `["a", "b", "c"]`,
+	query: `$[::-1]`,
+	want:  []string{`/2`, `"c"`, `/1`, `"b"`, `/0`, `"a"`},
+}, {
+	in:    `["a", "b", "c"]`,
+	query: `$[0:3:0]`,
+	want:  nil,
+}, {
+	in:    `{"a/b": {"~": 1}}`,
+	query: `$['a/b']['~']`,
+	want:  []string{`/a~1b/~0`, `1`},
+}, {
+	in:    `[3, 5, 1, 2, 4, 6, {"b": "j"}, {"b": "k"}, {"b": {}}, {"b": "kilo"}]`,
+	query: `$[?@.b == 'kilo']`,
+	want:  []string{`/9`, `{"b": "kilo"}`},
+}, {
+	in:    `[3, 5, 1, 2, 4, 6, {"b": "j"}, {"b": "k"}, {"b": {}}, {"b": "kilo"}]`,
+	query: `$[?@ > 3.5]`,
+	want:  []string{`/1`, `5`, `/4`, `4`, `/5`, `6`},
+}, {
+	in:    `[3, 5, 1, 2, 4, 6, {"b": "j"}, {"b": "k"}, {"b": {}}, {"b": "kilo"}]`,
+	query: `$[?@.b]`,
+	want:  []string{`/6`, `{"b": "j"}`, `/7`, `{"b": "k"}`, `/8`, `{"b": {}}`, `/9`, `{"b": "kilo"}`},
+}, {
+	in:    `[3, 5, 1, 2, 4, 6, {"b": "j"}, {"b": "k"}, {"b": {}}, {"b": "kilo"}]`,
+	query: `$[?@<2 || @.b == "k"]`,
+	want:  []string{`/2`, `1`, `/7`, `{"b": "k"}`},
+}, {
+	in:    `[3, 5, 1, 2, 4, 6, {"b": "j"}, {"b": "k"}, {"b": {}}, {"b": "kilo"}]`,
+	query: `$[?match(@.b, "[jk]")]`,
+	want:  []string{`/6`, `{"b": "j"}`, `/7`, `{"b": "k"}`},
+}, {
+	in:    `[3, 5, 1, 2, 4, 6, {"b": "j"}, {"b": "k"}, {"b": {}}, {"b": "kilo"}]`,
+	query: `$[?search(@.b, "[jk]")]`,
+	want:  []string{`/6`, `{"b": "j"}`, `/7`, `{"b": "k"}`, `/9`, `{"b": "kilo"}`},
+}, {
+	in:    `{"a": {"b": [1, 2]}, "c": {"b": [3]}, "d": {"b": "xyz"}, "e": {}}`,
+	query: `$[?length(@.b) >= 2]`,
+	want:  []string{`/a`, `{"b": [1, 2]}`, `/d`, `{"b": "xyz"}`},
+}, {
+	in:    `{"a": {"b": [1, 2]}, "c": {"b": [3]}, "d": {"b": "xyz"}, "e": {}}`,
+	query: `$[?count(@.*) == 0]`,
+	want:  []string{`/e`, `{}`},
+}, {
+	in:    `{"a": {"b": [1, 2]}, "c": {"b": [3]}, "d": {"b": "xyz"}, "e": {}}`,
+	query: `$[?value(@..b) == "xyz"]`,
+	want:  []string{`/d`, `{"b": "xyz"}`},
+}, {
+	in:    `{"a": {"b": [1, 2]}, "c": {"b": [3]}, "d": {"b": "xyz"}, "e": {}}`,
+	query: `$[?!(@.b == 'xyz') && @.b]`,
+	want:  []string{`/a`, `{"b": [1, 2]}`, `/c`, `{"b": [3]}`},
+}, {
+	in:    `[{"a": null}, {"a": 1}, {}]`,
+	query: `$[?@.a == null]`,
+	want:  []string{`/0`, `{"a": null}`},
+}, {
+	in:    `[{"a": null}, {"a": 1}, {}]`,
+	query: `$[?@.a == @.b]`,
+	want:  []string{`/2`, `{}`},
+}, {
+	in:    `[9007199254740992, 9007199254740993, 1e400, 1.0e401]`,
+	query: `$[?@ == 9007199254740993 || @ > 1e400]`,
+	want:  []string{`/1`, `9007199254740993`, `/3`, `1.0e401`},
+}, {
+	in:    `["\ud800", "\udc00", "\ufffd", "\u00e9", "e\u0301"]`,
+	query: `$[?@ == $[0] || @ < "e\u0302"]`,
+	want:  []string{`/0`, `"\ud800"`, `/4`, `"e\u0301"`},
+}, {
+	in:    `[{"\ud800": 1}, {"\udc00": 1}, {"\ufffd": 1}]`,
+	query: `$[?@ == $[0]]`,
+	want:  []string{`/0`, `{"\ud800": 1}`},
+}, {
+	in:      `{}`,
+	query:   `store`,
+	wantErr: `hujson: invalid JSONPath query at offset 0: query must start with '$'`,
+}, {
+	in:      `{}`,
+	query:   `$[?@.* == 1]`,
+	wantErr: `hujson: invalid JSONPath query at offset 6: non-singular query is not comparable`,
+}, {
+	in:      `{}`,
+	query:   `$[?length(@.a)]`,
+	wantErr: `hujson: invalid JSONPath query at offset 14: function length() result is not a logical value`,
+}, {
+	in:      `{}`,
+	query:   `$[?foo(@.a)]`,
+	wantErr: `hujson: invalid JSONPath query at offset 3: unknown function foo()`,
+}, {
+	in:      `{}`,
+	query:   `$[-0]`,
+	wantErr: `hujson: invalid JSONPath query at offset 2: invalid integer "-0"`,
+}, {
+	in:      `{}`,
+	query:   `$[9007199254740992]`,
+	wantErr: `hujson: invalid JSONPath query at offset 2: invalid integer "9007199254740992"`,
+}, {
+	in:      `{}`,
+	query:   `$.a b`,
+	wantErr: `hujson: invalid JSONPath query at offset 3: unexpected character ' '`,
+}, {
+	in:      `{}`,
+	query:   `$[?!@.a == 1]`,
+	wantErr: `hujson: invalid JSONPath query at offset 12: comparison must be parenthesized to be negated`,
+}}
+
+func TestQuery(t *testing.T) {
+	for _, tt := range testdataQuery {
+		t.Run("", func(t *testing.T) {
+			v, err := Parse([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("Parse error: %v", err)
+			}
+			seq, err := v.Query(tt.query)
+			if gotErr := errorString(err); gotErr != tt.wantErr {
+				t.Fatalf("Query(%q) error = %v, want %v", tt.query, gotErr, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var got []string
+			for ptr, v2 := range seq {
+				if v.Find(ptr) != v2 {
+					t.Errorf("Find(%q) does not match the queried value", ptr)
+				}
+				got = append(got, ptr, strings.TrimSpace(string(v2.Pack())))
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Query(%q) mismatch (-want +got):\n%s", tt.query, diff)
+			}
+		})
+	}
+}
+
+func TestQueryMutate(t *testing.T) {
+	v, err := Parse([]byte(queryInput))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	seq, err := v.Query(`$..[?@.price > 20].price`)
+	if err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	for _, v2 := range seq {
+		v2.Value = Int(20)
+	}
+	want := strings.NewReplacer(`22.99`, `20`, `399`, `20`).Replace(queryInput)
+	if diff := cmp.Diff(want, v.String()); diff != "" {
+		t.Errorf("Query mutation mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
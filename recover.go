@@ -0,0 +1,290 @@
+// Copyright (c) 2021 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hujson
+
+import (
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// ParseWithRecovery parses a HuJSON value as a Value,
+// recovering from syntax errors instead of stopping at the first one.
+// It returns a best-effort Value along with every syntax error encountered,
+// which is empty if and only if Parse would succeed on the same input.
+//
+// Parsing resynchronizes at commas, closing braces, and closing brackets.
+// Broken regions of the input are represented by a placeholder Literal
+// containing the raw invalid bytes (which may be empty),
+// for which Literal.IsValid reports false.
+// Unterminated comments extend until the end of the input.
+// Any input after the top-level value is discarded.
+// Unlike ParseWithOptions, no limits are enforced,
+// so callers should bound the length of untrusted input.
+//
+// Extra and Literal values in v will alias the provided input buffer.
+func ParseWithRecovery(b []byte) (Value, []*SyntaxError) {
+	p := recoverParser{b: b}
+	v, n := p.parseNext(0)
+	if n < len(b) {
+		p.report(newInvalidCharacterError(b[n:], "after top-level value", "end of input"), n)
+	}
+	return v, p.errs
+}
+
+// recoverParser is a parser that records syntax errors and continues.
+// It follows the grammar of parseNext.
+type recoverParser struct {
+	b    []byte
+	errs []*SyntaxError
+
+	// Position of the most recently reported error, which allows the
+	// position of subsequent errors to be computed incrementally.
+	pos          int
+	line, column int
+}
+
+// report records err as occurring at offset n.
+// Errors at the same offset as the previous error are discarded since
+// they are usually a consequence of that error.
+func (p *recoverParser) report(err error, n int) {
+	if len(p.errs) > 0 && p.errs[len(p.errs)-1].Offset == int64(n) {
+		return
+	}
+	if n < p.pos || p.line == 0 {
+		p.pos, p.line, p.column = 0, 1, 1
+	}
+	p.line, p.column = advancePosition(p.line, p.column, p.b[p.pos:n])
+	p.pos = n
+	e := newPositionedError(err, p.b[n:], 0, int64(n)).(*SyntaxError)
+	e.Line, e.Column = p.line, p.column
+	p.errs = append(p.errs, e)
+}
+
+// parseNext parses the next value with surrounding whitespace and comments.
+//
+// Like parser.parseNext, it uses an explicit stack of objects and arrays
+// (rather than recursion) so that deeply nested input does not grow
+// the goroutine stack.
+func (p *recoverParser) parseNext(n int) (v Value, _ int) {
+	var buf [8]parseFrame
+	stack := buf[:0] // objects and arrays currently being parsed
+
+parseValue:
+	// Consume leading whitespace and comments.
+	v = Value{}
+	n0 := n
+	if n = p.consumeExtra(n); n > n0 {
+		v.BeforeExtra = p.b[n0:n:n]
+	}
+	v.StartOffset = n
+
+	// Parse the next value.
+	if n < len(p.b) {
+		var top *parseFrame
+		if len(stack) > 0 {
+			top = &stack[len(stack)-1]
+		}
+		switch p.b[n] {
+		case '{', '[':
+			f := parseFrame{val: v}
+			if p.b[n] == '{' {
+				f.obj = new(Object)
+			} else {
+				f.arr = new(Array)
+			}
+			stack = append(stack, f)
+			n++
+			goto parseValue
+		case '}':
+			// The end of an object is permitted in place of a member name.
+			if top != nil && top.obj != nil && !top.inValue {
+				setTrailingComma(top.obj, len(top.obj.Members) > 0)
+				top.obj.AfterExtra = p.b[n0:n:n]
+				n += len(`}`)
+				goto endComposite
+			}
+		case ']':
+			// The end of an array is permitted in place of an element.
+			if top != nil && top.arr != nil {
+				setTrailingComma(top.arr, len(top.arr.Elements) > 0)
+				top.arr.AfterExtra = p.b[n0:n:n]
+				n += len(`]`)
+				goto endComposite
+			}
+		}
+	}
+	{
+		var p2 parser
+		lit, n2, err := p2.parseLiteral(n, p.b)
+		if err != nil {
+			p.report(err, n2)
+			n2 = p.skipInvalid(n)
+			lit = Literal(p.b[n:n2:n2])
+		}
+		v.Value, n = lit, n2
+	}
+	goto endValue
+
+endComposite:
+	// Pop the object or array that was just completed.
+	{
+		f := &stack[len(stack)-1]
+		v = f.val
+		v.Value = f.composite()
+		*f = parseFrame{}
+		stack = stack[:len(stack)-1]
+	}
+
+endValue:
+	v.EndOffset = n
+
+	// Consume trailing whitespace and comments.
+	if n = p.consumeExtra(n); n > v.EndOffset {
+		v.AfterExtra = p.b[v.EndOffset:n:n]
+	}
+	if len(stack) == 0 {
+		return v, n
+	}
+
+	// Handle the value according to the parent object or array.
+	switch top := &stack[len(stack)-1]; {
+	case top.obj != nil && !top.inValue:
+		if v.Value.Kind() != '"' {
+			p.report(newInvalidCharacterError(p.b[v.StartOffset:], "at start of object name", "object name"), v.StartOffset)
+		}
+
+		// Parse the colon.
+		switch {
+		case len(p.b) == n:
+			p.report(newSyntaxError("':'", fmt.Errorf("parsing object after name: %w", io.ErrUnexpectedEOF)), n)
+		case p.b[n] != ':':
+			p.report(newInvalidCharacterError(p.b[n:], "after object name", "':'"), n)
+		default:
+			n++
+		}
+		top.name, top.inValue = v, true
+		goto parseValue
+	case top.obj != nil:
+		obj := top.obj
+		obj.Members = append(obj.Members, ObjectMember{top.name, v})
+		top.name, top.inValue = Value{}, false
+		switch {
+		case len(p.b) == n:
+			p.report(newSyntaxError("',' or '}'", fmt.Errorf("parsing object after value: %w", io.ErrUnexpectedEOF)), n)
+			closeObject(obj)
+			goto endComposite
+		case p.b[n] == ',':
+			n++
+		case p.b[n] == '}':
+			closeObject(obj)
+			n += len(`}`)
+			goto endComposite
+		case p.b[n] == ']':
+			// Assume that the object was never closed.
+			p.report(newInvalidCharacterError(p.b[n:], "after object value (expecting ',' or '}')", "',' or '}'"), n)
+			closeObject(obj)
+			goto endComposite
+		default:
+			// Assume that a comma was omitted.
+			p.report(newInvalidCharacterError(p.b[n:], "after object value (expecting ',' or '}')", "',' or '}'"), n)
+		}
+		goto parseValue
+	default:
+		arr := top.arr
+		arr.Elements = append(arr.Elements, v)
+		switch {
+		case len(p.b) == n:
+			p.report(newSyntaxError("',' or ']'", fmt.Errorf("parsing array after value: %w", io.ErrUnexpectedEOF)), n)
+			closeArray(arr)
+			goto endComposite
+		case p.b[n] == ',':
+			n++
+		case p.b[n] == ']':
+			closeArray(arr)
+			n += len(`]`)
+			goto endComposite
+		case p.b[n] == '}':
+			// Assume that the array was never closed.
+			p.report(newInvalidCharacterError(p.b[n:], "after array value (expecting ',' or ']')", "',' or ']'"), n)
+			closeArray(arr)
+			goto endComposite
+		default:
+			// Assume that a comma was omitted.
+			p.report(newInvalidCharacterError(p.b[n:], "after array value (expecting ',' or ']')", "',' or ']'"), n)
+		}
+		goto parseValue
+	}
+}
+
+func closeObject(obj *Object) *Object {
+	// Move AfterExtra from last value to AfterExtra of the object.
+	obj.AfterExtra = obj.Members[len(obj.Members)-1].Value.AfterExtra
+	obj.Members[len(obj.Members)-1].Value.AfterExtra = nil
+	return obj
+}
+
+func closeArray(arr *Array) *Array {
+	// Move AfterExtra from last value to AfterExtra of the array.
+	arr.AfterExtra = arr.Elements[len(arr.Elements)-1].AfterExtra
+	arr.Elements[len(arr.Elements)-1].AfterExtra = nil
+	return arr
+}
+
+// consumeExtra consumes leading whitespace and comments.
+// Comments with invalid UTF-8 are consumed as is,
+// while unterminated comments consume the remainder of the input.
+func (p *recoverParser) consumeExtra(n int) int {
+	for {
+		n2, err := consumeExtra(n, p.b)
+		if err == nil {
+			return n2
+		}
+		p.report(err, n2)
+		nc := consumeComment(p.b[n2:])
+		if nc <= 0 {
+			return len(p.b)
+		}
+		n = n2 + nc
+	}
+}
+
+// skipInvalid returns the end of an invalid value starting at n.
+// It stops at whitespace or delimiters where parsing may resume,
+// but always skips at least one character unless n is at the end of input
+// or at a comma, closing brace, or closing bracket.
+func (p *recoverParser) skipInvalid(n int) int {
+	b := p.b
+	if n == len(b) || b[n] == ',' || b[n] == '}' || b[n] == ']' {
+		return n
+	}
+
+	// Strings cannot contain raw newlines, so stop at the end of the line.
+	if b[n] == '"' {
+		for n++; n < len(b); n++ {
+			switch b[n] {
+			case '\\':
+				n++
+			case '"':
+				return n + 1
+			case '\n':
+				return n
+			}
+		}
+		return len(b)
+	}
+
+	_, size := utf8.DecodeRune(b[n:])
+	n += size
+	for n < len(b) {
+		switch b[n] {
+		case ' ', '\t', '\r', '\n', ',', ':', '{', '}', '[', ']', '"', '/':
+			return n
+		}
+		_, size := utf8.DecodeRune(b[n:])
+		n += size
+	}
+	return n
+}
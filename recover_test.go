@@ -0,0 +1,115 @@
+// Copyright (c) 2021 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hujson
+
+import (
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestParseWithRecovery(t *testing.T) {
+	tests := []struct {
+		in       string
+		want     string // packed output of the recovered value
+		wantErrs []string
+	}{{
+		in:   `{"k":"v"}`,
+		want: `{"k":"v"}`,
+	}, {
+		in:       ``,
+		want:     ``,
+		wantErrs: []string{"hujson: line 1, column 1: parsing value: unexpected EOF"},
+	}, {
+		in:   "[1, 2 3, ;, 4,,]",
+		want: "[1, 2 ,3, ;, 4,,]",
+		wantErrs: []string{
+			"hujson: line 1, column 7: invalid character '3' after array value (expecting ',' or ']')",
+			"hujson: line 1, column 10: invalid character ';' at start of value",
+			"hujson: line 1, column 15: invalid character ',' at start of value",
+		},
+	}, {
+		in:   "{\n\t\"a\": 1\n\t\"b\" 2,\n\tnull: 3,\n\t\"c\": tru,\n\t\"d\": [1, 2},\n}",
+		want: "{\n\t\"a\": 1\n\t,\"b\" :2,\n\tnull: 3,\n\t\"c\": tru,\n\t\"d\": [1, 2]}",
+		wantErrs: []string{
+			"hujson: line 3, column 2: invalid character '\"' after object value (expecting ',' or '}')",
+			"hujson: line 3, column 6: invalid character '2' after object name",
+			"hujson: line 4, column 2: invalid character 'n' at start of object name",
+			"hujson: line 5, column 7: invalid literal: tru",
+			"hujson: line 6, column 12: invalid character '}' after array value (expecting ',' or ']')",
+			"hujson: line 6, column 13: invalid character ',' after top-level value",
+		},
+	}, {
+		in:       `{"a": "unterminated` + "\n" + `, "b": 2}`,
+		want:     `{"a": "unterminated` + "\n" + `, "b": 2}`,
+		wantErrs: []string{"hujson: line 1, column 7: invalid literal: \"unterminated\n, \""},
+	}, {
+		in:   `[1, /* comment`,
+		want: `[1, /* comment]`,
+		wantErrs: []string{
+			"hujson: line 1, column 5: parsing comment: unexpected EOF",
+			"hujson: line 1, column 15: parsing value: unexpected EOF",
+		},
+	}, {
+		in:       `[1, 2]]`,
+		want:     `[1, 2]`,
+		wantErrs: []string{"hujson: line 1, column 7: invalid character ']' after top-level value"},
+	}}
+
+	for _, tt := range tests {
+		v, errs := ParseWithRecovery([]byte(tt.in))
+		if got := v.String(); got != tt.want {
+			t.Errorf("ParseWithRecovery(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		var gotErrs []string
+		for _, err := range errs {
+			gotErrs = append(gotErrs, err.Error())
+		}
+		if diff := cmp.Diff(tt.wantErrs, gotErrs); diff != "" {
+			t.Errorf("ParseWithRecovery(%q) errors mismatch (-want +got):\n%s", tt.in, diff)
+		}
+	}
+}
+
+func TestParseWithRecoveryParity(t *testing.T) {
+	for _, tt := range testdata {
+		wantVal, wantErr := Parse([]byte(tt.in))
+		gotVal, gotErrs := ParseWithRecovery([]byte(tt.in))
+		if wantErr == nil {
+			if len(gotErrs) > 0 {
+				t.Errorf("ParseWithRecovery(%q) errors = %v, want none", tt.in, gotErrs)
+			}
+			if diff := cmp.Diff(wantVal, gotVal, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("ParseWithRecovery(%q) mismatch (-want +got):\n%s", tt.in, diff)
+			}
+			continue
+		}
+		// The first error must match the error reported by Parse.
+		if len(gotErrs) == 0 || !reflect.DeepEqual(error(gotErrs[0]), wantErr) {
+			t.Errorf("ParseWithRecovery(%q) errors = %v, want first error %v", tt.in, gotErrs, wantErr)
+		}
+	}
+}
+
+func TestParseWithRecoveryDeepNesting(t *testing.T) {
+	// Limit the stack size to verify that the stack does not grow with depth.
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+	const depth = 100000
+	in := strings.Repeat(`[{"k":`, depth) + "nul" + strings.Repeat("]", depth)
+	v, errs := ParseWithRecovery([]byte(in))
+	if got, want := v.Pack(), strings.TrimSuffix(in, strings.Repeat("]", depth))+strings.Repeat("}]", depth); string(got) != want {
+		t.Errorf("Pack mismatch")
+	}
+	if got, want := len(errs), 1+depth; got != want {
+		t.Errorf("ParseWithRecovery reported %d errors, want %d", got, want)
+	}
+	if got, want := errs[len(errs)-1].Column, len(in); got != want {
+		t.Errorf("last error column = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright (c) 2021 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hujson
+
+import (
+	"bytes"
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// SortObjects sorts the members of an object according to the less function,
+// which reports whether the unescaped member name a sorts before b.
+// If less is nil, then names are sorted in lexicographical order.
+// The sort is stable such that members with duplicate names
+// retain their relative order.
+// If recursive, then objects nested anywhere within v are also sorted.
+//
+// Comments associated with each member are moved along with the member
+// according to the same heuristics used by the "move" operation of Patch.
+// Members that are already sorted are left untouched.
+// It is recommended that Format be called afterwards.
+func (v *Value) SortObjects(less func(a, b string) bool, recursive bool) {
+	if less == nil {
+		less = func(a, b string) bool { return a < b }
+	}
+	v.sortObjects(less, recursive)
+	v.UpdateOffsets()
+}
+func (v *Value) sortObjects(less func(a, b string) bool, recursive bool) {
+	if !recursive {
+		if obj, ok := v.Value.(*Object); ok {
+			obj.sort(less)
+		}
+		return
+	}
+	for v2 := range v.All() {
+		if obj, ok := v2.Value.(*Object); ok {
+			obj.sort(less)
+		}
+	}
+}
+
+func (obj *Object) sort(less func(a, b string) bool) {
+	names := make([]string, len(obj.Members))
+	for i, m := range obj.Members {
+		names[i] = m.Name.Value.(Literal).String()
+	}
+	sortComposite(obj, func(i, j int) int {
+		switch {
+		case less(names[i], names[j]):
+			return -1
+		case less(names[j], names[i]):
+			return +1
+		default:
+			return 0
+		}
+	})
+}
+
+// Sort sorts the elements of the array according to the cmp function,
+// which must return a negative number when a < b, a positive number when a > b,
+// and zero when a == b. The sort is stable.
+//
+// Comments associated with each element are moved along with the element
+// according to the same heuristics used by the "move" operation of Patch.
+// Elements that are already sorted are left untouched.
+// It is recommended that Format be called afterwards.
+func (arr *Array) Sort(cmp func(a, b *Value) int) {
+	sortComposite(arr, func(i, j int) int {
+		return cmp(&arr.Elements[i], &arr.Elements[j])
+	})
+}
+
+// sortComposite stably sorts the members or elements of comp,
+// where compare compares the i-th and j-th members or elements.
+func sortComposite(comp composite, compare func(i, j int) int) {
+	n := comp.length()
+	order := make([]int, n) // index of the original member or element for each position
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, compare)
+	if slices.IsSorted(order) {
+		return
+	}
+
+	// Split the extra before each member or element (and before the closing
+	// bracket) into the trailing comments of the preceding member or element,
+	// the whitespace that remains in place, and
+	// the leading comments of the succeeding member or element.
+	// This uses the same classification as the "move" operation of Patch.
+	var (
+		leading      = make([]Extra, n)
+		trailing     = make([]Extra, n+1) // trailing[0] follows the opening bracket
+		trailingLine = make([]bool, n+1)  // whether trailing had its final newline stripped
+		positional   = make([]Extra, n+1)
+	)
+	for i := 0; i <= n; i++ {
+		b := *comp.beforeExtraAt(i)
+		prevEnd, currStart := b.classifyComments()
+		currStart += consumeWhitespace(b[currStart:])
+		trailing[i] = b[:prevEnd]
+		if bytes.HasSuffix(trailing[i], newline) {
+			trailing[i] = trailing[i][:len(trailing[i])-len(newline)]
+			trailingLine[i] = true
+			prevEnd -= len(newline)
+		}
+		if i < n {
+			leading[i] = b[currStart:]
+			positional[i] = b[prevEnd:currStart]
+		} else {
+			positional[i] = b[prevEnd:] // comments before the closing bracket stay
+		}
+	}
+
+	// Reassemble the composite in sorted order.
+	trailingComma := hasTrailingComma(comp)
+	var afterExtras []*Extra
+	switch comp := comp.(type) {
+	case *Object:
+		members := slices.Clone(comp.Members)
+		for i, j := range order {
+			comp.Members[i] = members[j]
+			afterExtras = append(afterExtras, &comp.Members[i].Value.AfterExtra)
+		}
+	case *Array:
+		elems := slices.Clone(comp.Elements)
+		for i, j := range order {
+			comp.Elements[i] = elems[j]
+			afterExtras = append(afterExtras, &comp.Elements[i].AfterExtra)
+		}
+	}
+	for _, b := range afterExtras {
+		if len(*b) == 0 {
+			*b = nil
+		}
+	}
+	for i := 0; i <= n; i++ {
+		prev := 0 // index into trailing for the preceding member or element
+		if i > 0 {
+			prev = order[i-1] + 1
+		}
+		var b Extra
+		b = append(b, trailing[prev]...)
+		if trailingLine[prev] && !bytes.HasPrefix(positional[i], newline) {
+			b = append(b, newline...)
+		}
+		b = append(b, positional[i]...)
+		if i < n {
+			b = append(b, leading[order[i]]...)
+		}
+		*comp.beforeExtraAt(i) = b
+	}
+	setTrailingComma(comp, trailingComma)
+}
+
+// SortDirective is the text of a comment that marks an array
+// to be sorted by SortMarkedArrays (e.g., "// hujson:sort").
+const SortDirective = "hujson:sort"
+
+// SortMarkedArrays sorts every array within v that is marked with
+// a SortDirective comment, which must either be a leading comment of
+// the array value (or of the object member containing it) or
+// a line comment immediately following the opening bracket. For example:
+//
+//	{
+//		// hujson:sort
+//		"hosts": ["bravo", "alpha"],
+//		"tags": [ // hujson:sort
+//			"tag:zulu",
+//			"tag:alpha", // comment moved with "tag:alpha"
+//		],
+//	}
+//
+// Elements are sorted by kind in the order of null, false, true,
+// numbers, strings, arrays, and objects. Numbers are sorted by exact value,
+// strings are sorted by the code points of their unescaped value,
+// and arrays and objects are sorted by their minimized representation.
+// Comments associated with each element are moved with the element.
+// It is recommended that Format be called afterwards.
+func (v *Value) SortMarkedArrays() {
+	v.sortMarkedArrays(v.LeadingComments())
+	v.UpdateOffsets()
+}
+func (v *Value) sortMarkedArrays(leading []Comment) {
+	// Find the marked arrays, where each array is found before
+	// any arrays nested within it.
+	type entry struct {
+		v       *Value
+		leading []Comment
+	}
+	var marked []*Array
+	stack := []entry{{v, leading}}
+	for len(stack) > 0 {
+		e := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch comp := e.v.Value.(type) {
+		case *Object:
+			for i := range comp.Members {
+				m := &comp.Members[i]
+				stack = append(stack, entry{&m.Value, append(comp.LeadingComments(i), m.Value.LeadingComments()...)})
+			}
+		case *Array:
+			for i := range comp.Elements {
+				stack = append(stack, entry{&comp.Elements[i], comp.LeadingComments(i)})
+			}
+			if hasSortDirective(e.leading) || hasSortDirective(comp.beforeExtraAt(0).trailingComments()) {
+				marked = append(marked, comp)
+			}
+		}
+	}
+
+	// Sort nested arrays before the arrays containing them,
+	// since the order of elements depends on their contents.
+	for _, arr := range slices.Backward(marked) {
+		arr.Sort(compareValues)
+	}
+}
+
+func hasSortDirective(comments []Comment) bool {
+	for _, c := range comments {
+		if strings.TrimSpace(c.Text) == SortDirective {
+			return true
+		}
+	}
+	return false
+}
+
+// compareValues compares two values as documented in SortMarkedArrays.
+func compareValues(x, y *Value) int {
+	rank := func(k Kind) int { return strings.IndexByte("nft0\"[{", byte(k)) }
+	kx, ky := x.Value.Kind(), y.Value.Kind()
+	if c := cmp.Compare(rank(kx), rank(ky)); c != 0 {
+		return c
+	}
+	switch kx {
+	case '0':
+		rx, okx := x.Value.(Literal).Rat()
+		ry, oky := y.Value.(Literal).Rat()
+		if okx && oky && rx.Cmp(ry) != 0 {
+			return rx.Cmp(ry)
+		}
+	case '"':
+		return slices.Compare(x.Value.(Literal).decodeExact(), y.Value.(Literal).decodeExact())
+	}
+	keys := minimizedKeys([]Value{*x, *y})
+	return strings.Compare(keys[0], keys[1])
+}
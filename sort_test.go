@@ -0,0 +1,161 @@
+// Copyright (c) 2021 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hujson
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSortObjects(t *testing.T) {
+	in := `// Header
+
+{
+	// Comment about zulu
+	"zulu": 1, // zulu trailing
+
+	// Comment about alpha
+	"alpha": {"y": [{"b": 0, "a": 1}], "x": 2},
+	"mike": 3 /* mike */, // mike trailing
+}
+`
+	tests := []struct {
+		name      string
+		less      func(a, b string) bool
+		recursive bool
+		want      string
+	}{{
+		name: "Shallow",
+		want: `// Header
+
+{
+	// Comment about alpha
+	"alpha": {"y": [{"b": 0, "a": 1}], "x": 2},
+
+	"mike": 3 /* mike */, // mike trailing
+	// Comment about zulu
+	"zulu": 1, // zulu trailing
+}
+`,
+	}, {
+		name:      "Recursive",
+		recursive: true,
+		want: `// Header
+
+{
+	// Comment about alpha
+	"alpha": {"x": 2, "y": [{"a": 1, "b": 0}]},
+
+	"mike": 3 /* mike */, // mike trailing
+	// Comment about zulu
+	"zulu": 1, // zulu trailing
+}
+`,
+	}, {
+		name: "Reverse",
+		less: func(a, b string) bool { return a > b },
+		want: `// Header
+
+{
+	// Comment about zulu
+	"zulu": 1, // zulu trailing
+
+	"mike": 3 /* mike */, // mike trailing
+	// Comment about alpha
+	"alpha": {"y": [{"b": 0, "a": 1}], "x": 2},
+}
+`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := Parse([]byte(in))
+			if err != nil {
+				t.Fatalf("Parse error: %v", err)
+			}
+			v.SortObjects(tt.less, tt.recursive)
+			if diff := cmp.Diff(tt.want, v.String()); diff != "" {
+				t.Errorf("SortObjects mismatch (-want +got):\n%s", diff)
+			}
+			if _, err := Parse(v.Pack()); err != nil {
+				t.Errorf("Parse error: %v", err)
+			}
+		})
+	}
+}
+
+func TestArraySort(t *testing.T) {
+	in := `[
+	// Comment about 3
+	3, // three
+	1,
+	2 /* two */,
+]`
+	want := `[
+	1,
+	2 /* two */,
+	// Comment about 3
+	3, // three
+]`
+	v, err := Parse([]byte(in))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	v.Value.(*Array).Sort(func(a, b *Value) int {
+		return int(a.Value.(Literal).Int() - b.Value.(Literal).Int())
+	})
+	if diff := cmp.Diff(want, v.String()); diff != "" {
+		t.Errorf("Sort mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSortMarkedArrays(t *testing.T) {
+	in := `// hujson:sort
+[
+	{
+		// hujson:sort
+		"hosts": ["bravo", "alpha", 10, 9, null, true, false, {}, []],
+		"unsorted": ["bravo", "alpha"],
+		"tags": [ // hujson:sort
+			"tag:zulu",
+			"tag:alpha", // alpha
+		],
+	},
+	/* hujson:sort */ [2, 1],
+	[1, 0],
+	// hujson:sort
+	[9007199254740993, 9007199254740992, 1e400, -1e400, 1.0e401],
+	// hujson:sort
+	["\ufffd", "\udc00", "\ud800"],
+]
+`
+	want := `// hujson:sort
+[
+	// hujson:sort
+	["\ud800", "\udc00", "\ufffd"],
+	// hujson:sort
+	[-1e400, 9007199254740992, 9007199254740993, 1e400, 1.0e401],
+	[1, 0],
+	/* hujson:sort */ [1, 2],
+	{
+		// hujson:sort
+		"hosts": [null, false, true, 9, 10, "alpha", "bravo", [], {}],
+		"unsorted": ["bravo", "alpha"],
+		"tags": [ // hujson:sort
+			"tag:alpha", // alpha
+			"tag:zulu",
+		],
+	},
+]
+`
+	v, err := Parse([]byte(in))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	v.SortMarkedArrays()
+	if diff := cmp.Diff(want, v.String()); diff != "" {
+		t.Errorf("SortMarkedArrays mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -4,27 +4,28 @@
 
 package hujson
 
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"unicode/utf16"
+)
+
 // IsStandard reports whether this is standard JSON
 // by checking that there are no comments and no trailing commas.
 func (v Value) IsStandard() bool {
 	return v.isStandard()
 }
 func (v *Value) isStandard() bool {
-	if !v.BeforeExtra.IsStandard() {
-		return false
-	}
-	if comp, ok := v.Value.(composite); ok {
-		for v2 := range comp.allValues() {
-			if !v2.isStandard() {
+	for v2 := range v.All() {
+		if !v2.BeforeExtra.IsStandard() || !v2.AfterExtra.IsStandard() {
+			return false
+		}
+		if comp, ok := v2.Value.(composite); ok {
+			if hasTrailingComma(comp) || !comp.afterExtra().IsStandard() {
 				return false
 			}
 		}
-		if hasTrailingComma(comp) || !comp.afterExtra().IsStandard() {
-			return false
-		}
-	}
-	if !v.AfterExtra.IsStandard() {
-		return false
 	}
 	return true
 }
@@ -44,15 +45,14 @@ func (v *Value) Minimize() {
 	v.UpdateOffsets()
 }
 func (v *Value) minimize() {
-	v.BeforeExtra = nil
-	if v2, ok := v.Value.(composite); ok {
-		for v3 := range v2.allValues() {
-			v3.minimize()
+	for v2 := range v.All() {
+		v2.BeforeExtra = nil
+		if comp, ok := v2.Value.(composite); ok {
+			setTrailingComma(comp, false)
+			*comp.afterExtra() = nil
 		}
-		setTrailingComma(v2, false)
-		*v2.afterExtra() = nil
+		v2.AfterExtra = nil
 	}
-	v.AfterExtra = nil
 }
 
 // Standardize strips any features specific to HuJSON from v,
@@ -64,18 +64,18 @@ func (v *Value) Standardize() {
 	v.UpdateOffsets() // should be noop if offsets are already correct
 }
 func (v *Value) standardize() {
-	v.BeforeExtra.standardize()
-	if comp, ok := v.Value.(composite); ok {
-		for v2 := range comp.allValues() {
-			v2.standardize()
-		}
-		if last := comp.lastValue(); last != nil && last.AfterExtra != nil {
-			*comp.afterExtra() = append(append(last.AfterExtra, ' '), *comp.afterExtra()...)
-			last.AfterExtra = nil
+	for v2 := range v.All() {
+		v2.BeforeExtra.standardize()
+		if comp, ok := v2.Value.(composite); ok {
+			// Move the trailing comma into the extra before the closing bracket.
+			if last := comp.lastValue(); last != nil && last.AfterExtra != nil {
+				*comp.afterExtra() = append(append(last.AfterExtra, ' '), *comp.afterExtra()...)
+				last.AfterExtra = nil
+			}
+			comp.afterExtra().standardize()
 		}
-		comp.afterExtra().standardize()
+		v2.AfterExtra.standardize()
 	}
-	v.AfterExtra.standardize()
 }
 func (b *Extra) standardize() {
 	for i, c := range *b {
@@ -87,3 +87,98 @@ func (b *Extra) standardize() {
 		}
 	}
 }
+
+// Canonicalize transforms v into the canonical form of JSON per RFC 8785,
+// which is suitable for producing a deterministic representation of
+// the value for hashing or signing.
+// All whitespace, comments, and trailing commas are removed,
+// object members are sorted by name in UTF-16 code unit order,
+// strings are escaped as minimally as possible, and
+// numbers are formatted according to ECMAScript (i.e., as IEEE 754 doubles).
+//
+// It reports an error if v is not valid HuJSON, contains duplicate object names,
+// contains strings with invalid Unicode (including unpaired surrogates), or
+// contains numbers that cannot be represented as an IEEE 754 double.
+// If an error is reported, then v is left unmodified.
+func (v *Value) Canonicalize() error {
+	if _, err := Parse(v.Pack()); err != nil {
+		return err
+	}
+	v2 := v.Clone()
+	v2.minimize()
+	if err := v2.canonicalize(); err != nil {
+		return err
+	}
+	v2.UpdateOffsets()
+	*v = v2
+	return nil
+}
+func (v *Value) canonicalize() error {
+	var objects []*Object
+	for pointer, v := range v.walkPointers(nil) {
+		switch v2 := v.Value.(type) {
+		case Literal:
+			lit, err := v2.canonicalize()
+			if err != nil {
+				return fmt.Errorf("hujson: cannot canonicalize value at %q: %w", pointer, err)
+			}
+			v.Value = lit
+		case *Object:
+			seen := make(map[string]bool)
+			for i := range v2.Members {
+				name := &v2.Members[i].Name
+				lit, err := name.Value.(Literal).canonicalize()
+				if err != nil {
+					return fmt.Errorf("hujson: cannot canonicalize object name at %q: %w", pointer, err)
+				}
+				if seen[lit.String()] {
+					return fmt.Errorf("hujson: cannot canonicalize object at %q: duplicate name %s", pointer, string(lit))
+				}
+				seen[lit.String()] = true
+				name.Value = lit
+			}
+			objects = append(objects, v2)
+		}
+	}
+
+	// Sort the members of each object by name in UTF-16 code unit order.
+	for _, obj := range objects {
+		type member struct {
+			key []uint16 // name in UTF-16 code units
+			ObjectMember
+		}
+		members := make([]member, len(obj.Members))
+		for i, m := range obj.Members {
+			members[i] = member{utf16.Encode([]rune(m.Name.Value.(Literal).String())), m}
+		}
+		slices.SortFunc(members, func(x, y member) int {
+			return slices.Compare(x.key, y.key)
+		})
+		for i, m := range members {
+			obj.Members[i] = m.ObjectMember
+		}
+	}
+	return nil
+}
+
+// canonicalize returns the literal formatted according to RFC 8785.
+func (b Literal) canonicalize() (Literal, error) {
+	switch b.Kind() {
+	case '"':
+		if !b.isUnicodeValid() {
+			return nil, fmt.Errorf("invalid Unicode in string %s", string(b))
+		}
+		return String(b.String()), nil
+	case '0':
+		f, err := strconv.ParseFloat(string(b), 64)
+		if err != nil {
+			return nil, fmt.Errorf("number %s is not representable as an IEEE 754 double", string(b))
+		}
+		if f == 0 {
+			return Literal("0"), nil // RFC 8785, section 3.2.2.3 serializes -0 as 0
+		}
+		return Float(f), nil
+	default:
+		return b, nil
+	}
+}
@@ -0,0 +1,305 @@
+// Copyright (c) 2021 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hujson
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// Decoder reads and parses a sequence of HuJSON values from an input stream.
+// Input is read incrementally such that the entire stream
+// need not be held in memory at once.
+type Decoder struct {
+	r    io.Reader
+	opts ParseOptions
+	buf  []byte // buf[off:] is the unparsed input
+	off  int
+	err  error // sticky read error (e.g., io.EOF), syntax error, or limit error
+
+	// The unparsed input is only parsed again once it has doubled in length
+	// or the scanner finds the start of the token following a value,
+	// such that the cost of parsing is linear in the size of the input.
+	retryLen int
+	scan     tokenScanner
+
+	// Position of buf[0] within the input stream.
+	offset int64
+	line   int
+	column int
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r, line: 1, column: 1}
+}
+
+// NewDecoderWithOptions is like NewDecoder, but parses each value
+// according to opts as ParseWithOptions would. MaxBytes limits the length of each value
+// (including surrounding whitespace and comments) rather than the entire
+// input stream, which also bounds how much input the Decoder buffers.
+func NewDecoderWithOptions(r io.Reader, opts ParseOptions) *Decoder {
+	return &Decoder{r: r, opts: opts, line: 1, column: 1}
+}
+
+// minRead is the minimum number of bytes read from the underlying reader.
+const minRead = 512
+
+// Decode reads and parses the next HuJSON value from the input stream.
+// Multiple values may be concatenated together in the input,
+// in which case each call to Decode returns the next value.
+// Whitespace and comments after a value are consumed as part of that value
+// up to and including the end of the line on which the value ends,
+// so Decode returns as soon as that line is complete.
+// Whitespace and comments on subsequent lines precede the next value
+// and are discarded if no value follows.
+//
+// The StartOffset and EndOffset of the returned value are relative to
+// the start of the Value.BeforeExtra, such that parsing the output
+// of Value.Pack produces an identical value.
+// Extra and Literal values in v will alias an internal buffer,
+// which is never modified by subsequent calls to Decode.
+//
+// It returns io.EOF if the rest of the input stream contains only
+// whitespace and comments.
+// Syntax errors report the line and column relative to the entire input stream.
+// Once a syntax or limit error is reported, all subsequent calls return it.
+func (d *Decoder) Decode() (Value, error) {
+	for {
+		rest := d.buf[d.off:]
+		found := d.scan.found || d.scan.next(rest)
+		if d.err == nil && len(rest) < d.retryLen && !d.exceedsMaxBytes(len(rest)) && !found {
+			d.fill()
+			continue
+		}
+		b := rest
+		if d.scan.newline {
+			b = rest[:d.scan.pos] // value ends with the line
+		}
+		p := parser{opts: d.opts}
+		v, n, err := p.parseNext(0, b)
+		if !d.scan.newline && needMoreInput(b, n, err) {
+			switch {
+			case d.exceedsMaxBytes(len(rest)):
+				n, err = d.opts.MaxBytes, newLimitError("MaxBytes", d.opts.MaxBytes)
+			case d.err == nil:
+				d.retryLen = 2*len(rest) + 1
+				d.fill()
+				continue
+			case d.err != io.EOF:
+				return Value{}, d.err // sticky read error or syntax error
+			}
+		}
+		d.retryLen, d.scan = 0, tokenScanner{}
+		if d.exceedsMaxBytes(n) {
+			n, err = d.opts.MaxBytes, newLimitError("MaxBytes", d.opts.MaxBytes)
+		}
+		if err == nil && d.opts.RejectDuplicateNames {
+			position := func(n int) (int, int) { return d.lineColumn(rest, n) }
+			if n2, err2 := firstDuplicateName(&v, rest, position); err2 != nil {
+				n, err = n2, err2
+			}
+		}
+		switch {
+		case err == nil:
+			d.off += n
+			return v, nil
+		case Extra(rest).IsValid() && !d.exceedsMaxBytes(len(rest)):
+			return Value{}, io.EOF
+		default:
+			err = newPositionedError(err, rest, n, d.InputOffset())
+			switch e := err.(type) {
+			case *SyntaxError:
+				e.Line, e.Column = d.lineColumn(rest, n)
+			case *LimitError:
+				e.Line, e.Column = d.lineColumn(rest, n)
+			}
+			d.buf, d.off, d.err = nil, 0, err
+			return Value{}, err
+		}
+	}
+}
+
+// exceedsMaxBytes reports whether n bytes of unparsed input
+// exceeds the MaxBytes limit.
+func (d *Decoder) exceedsMaxBytes(n int) bool {
+	return d.opts.MaxBytes > 0 && n > d.opts.MaxBytes
+}
+
+// needMoreInput reports whether the result of parsing b may change
+// if more input were available.
+func needMoreInput(b []byte, n int, err error) bool {
+	if err != nil {
+		// Only errors at the very end of the input are recoverable,
+		// where the invalid token may be completed by subsequent input.
+		return errors.Is(err, io.ErrUnexpectedEOF) || isTruncatedToken(b[n:])
+	}
+	// A trailing literal, whitespace, or comment may still be continued.
+	return n == len(b) || (b[n] == '/' && n+len("/") == len(b))
+}
+
+// isTruncatedToken reports whether b consists entirely of a single token
+// that may become valid if followed by more input.
+func isTruncatedToken(b []byte) bool {
+	if len(b) == 0 || string(b) == "/" {
+		return true
+	}
+	for _, c := range b {
+		if !isLiteralChar(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// tokenScanner incrementally scans the unparsed input
+// to cheaply determine when a complete value may be available.
+// It only tracks the nesting depth and whether it is within
+// a string, comment, or literal, leaving validation to the parser.
+type tokenScanner struct {
+	pos      int // offset of the next byte to scan
+	depth    int
+	state    byte // one of the scan states below
+	complete bool // whether a complete top-level value has been scanned
+	found    bool // whether the end of the value has been found
+	newline  bool // whether the value ends with a newline at pos-1
+}
+
+const (
+	scanNormal byte = iota
+	scanLiteral
+	scanString
+	scanStringEscape
+	scanSlash
+	scanLineComment
+	scanBlockComment
+	scanBlockCommentStar
+)
+
+// next scans b[s.pos:] and reports whether the end of a complete top-level
+// value has been found, which is either the newline ending the line
+// on which the value ends or the start of the token following the value.
+func (s *tokenScanner) next(b []byte) bool {
+	for ; s.pos < len(b) && !s.found; s.pos++ {
+		c := b[s.pos]
+		switch s.state {
+		case scanLiteral:
+			if isLiteralChar(c) {
+				continue
+			}
+			s.state = scanNormal
+			s.complete = true
+			s.pos-- // rescan c as the start of the next token
+		case scanString:
+			switch c {
+			case '\\':
+				s.state = scanStringEscape
+			case '"':
+				s.state = scanNormal
+				s.complete = s.depth == 0
+			}
+		case scanStringEscape:
+			s.state = scanString
+		case scanSlash:
+			switch c {
+			case '/':
+				s.state = scanLineComment
+			case '*':
+				s.state = scanBlockComment
+			default:
+				s.found = true // invalid comment reported by the parser
+			}
+		case scanLineComment:
+			if c == '\n' {
+				s.state = scanNormal
+				s.found, s.newline = s.complete, s.complete
+			}
+		case scanBlockComment, scanBlockCommentStar:
+			switch {
+			case c == '*':
+				s.state = scanBlockCommentStar
+			case c == '/' && s.state == scanBlockCommentStar:
+				s.state = scanNormal
+			default:
+				s.state = scanBlockComment
+			}
+		default:
+			switch {
+			case c == '\n' && s.complete:
+				s.found, s.newline = true, true
+			case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			case c == '/':
+				s.state = scanSlash
+			case s.complete:
+				s.found = true
+				s.pos-- // leave pos at the start of the next token
+			case c == '"':
+				s.state = scanString
+			case c == '{' || c == '[':
+				s.depth++
+			case c == '}' || c == ']':
+				s.depth--
+				s.complete = s.depth <= 0
+			case isLiteralChar(c) && s.depth == 0:
+				s.state = scanLiteral
+			case s.depth == 0:
+				s.found = true // invalid character reported by the parser
+			}
+		}
+	}
+	return s.found
+}
+
+func isLiteralChar(c byte) bool {
+	return c == '-' || c == '+' || c == '.' ||
+		('a' <= c && c <= 'z') ||
+		('A' <= c && c <= 'Z') ||
+		('0' <= c && c <= '9')
+}
+
+// fill reads more data from the underlying reader into the buffer.
+func (d *Decoder) fill() {
+	// Discard the consumed input. Previously returned values may alias
+	// the current buffer, so the unparsed input must be moved to a new buffer.
+	if d.off > 0 {
+		d.offset += int64(d.off)
+		d.line, d.column = advancePosition(d.line, d.column, d.buf[:d.off])
+		d.buf = append(make([]byte, 0, max(2*len(d.buf[d.off:]), minRead)), d.buf[d.off:]...)
+		d.off = 0
+	}
+
+	// Grow the buffer if there is insufficient space to read into.
+	if cap(d.buf)-len(d.buf) < minRead {
+		d.buf = append(make([]byte, 0, 2*cap(d.buf)+minRead), d.buf...)
+	}
+
+	n, err := d.r.Read(d.buf[len(d.buf):cap(d.buf)])
+	d.buf = d.buf[:len(d.buf)+n]
+	if err != nil {
+		d.err = err
+	}
+}
+
+// lineColumn reports the line and column within the input stream
+// for offset n within the unparsed input b.
+func (d *Decoder) lineColumn(b []byte, n int) (line, column int) {
+	line, column = advancePosition(d.line, d.column, d.buf[:d.off])
+	return advancePosition(line, column, b[:n])
+}
+
+// InputOffset returns the offset within the input stream
+// of the next byte to be parsed.
+func (d *Decoder) InputOffset() int64 {
+	return d.offset + int64(d.off)
+}
+
+// advancePosition advances the line and column past b.
+func advancePosition(line, column int, b []byte) (int, int) {
+	if i := bytes.LastIndexByte(b, '\n'); i >= 0 {
+		return line + bytes.Count(b, newline), 1 + len(b) - (i + len("\n"))
+	}
+	return line, column + len(b)
+}
@@ -0,0 +1,288 @@
+// Copyright (c) 2021 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hujson
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestDecoder(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr error
+	}{{
+		in:      "",
+		wantErr: io.EOF,
+	}, {
+		in:      " // comment\n /* comment */ ",
+		wantErr: io.EOF,
+	}, {
+		in:   `null`,
+		want: []string{`null`},
+	}, {
+		in:   `123 456`,
+		want: []string{`123 `, `456`},
+	}, {
+		in:   "1//comment\n2/*comment*/3",
+		want: []string{"1//comment\n", "2/*comment*/", "3"},
+	}, {
+		in:   `{"k":[1,2,3,],}{"k":"v"}[]"string"`,
+		want: []string{`{"k":[1,2,3,],}`, `{"k":"v"}`, `[]`, `"string"`},
+	}, {
+		in:   "\n// Comment\n{\n\t\"key\": \"value\", // Comment\n}\n\n/* Comment */\n[\n\t1,\n\t2,\n]\n",
+		want: []string{"\n// Comment\n{\n\t\"key\": \"value\", // Comment\n}\n", "\n/* Comment */\n[\n\t1,\n\t2,\n]\n"},
+	}, {
+		in:   "{\"k\":1} /* a\nb */ // c\n{\"k\":2}\n// trailing\n",
+		want: []string{"{\"k\":1} /* a\nb */ // c\n", "{\"k\":2}\n"},
+	}, {
+		in:      "{}\n[\n\t1,\n\t2,\n\t;\n]",
+		want:    []string{"{}\n"},
+		wantErr: &SyntaxError{Offset: 14, Line: 5, Column: 2, Char: ';', Expected: "value", Err: errors.New("invalid character ';' at start of value")},
+	}, {
+		in:      "null\n[1, 2",
+		want:    []string{"null\n"},
+		wantErr: &SyntaxError{Offset: 10, Line: 2, Column: 6, Expected: "',' or ']'", Err: fmt.Errorf("parsing array after value: %w", io.ErrUnexpectedEOF)},
+	}, {
+		in:      "null /* comment",
+		wantErr: &SyntaxError{Offset: 5, Line: 1, Column: 6, Char: '/', Expected: "end of comment", Err: fmt.Errorf("parsing comment: %w", io.ErrUnexpectedEOF)},
+	}, {
+		in:      "true fals",
+		want:    []string{"true "},
+		wantErr: &SyntaxError{Offset: 5, Line: 1, Column: 6, Char: 'f', Expected: "null, boolean, or number", Err: errors.New("invalid literal: fals")},
+	}}
+
+	readers := []struct {
+		name string
+		wrap func(io.Reader) io.Reader
+	}{
+		{"Reader", func(r io.Reader) io.Reader { return r }},
+		{"OneByteReader", iotest.OneByteReader},
+		{"DataErrReader", iotest.DataErrReader},
+	}
+	for _, tt := range tests {
+		for _, rd := range readers {
+			t.Run(rd.name, func(t *testing.T) {
+				d := NewDecoder(rd.wrap(strings.NewReader(tt.in)))
+				var got []string
+				var gotErr error
+				for {
+					v, err := d.Decode()
+					if err != nil {
+						gotErr = err
+						break
+					}
+					got = append(got, v.String())
+				}
+				if diff := cmp.Diff(tt.want, got); diff != "" {
+					t.Errorf("Decode(%q) mismatch (-want +got):\n%s", tt.in, diff)
+				}
+				wantErr := tt.wantErr
+				if wantErr == nil {
+					wantErr = io.EOF
+				}
+				if !reflect.DeepEqual(gotErr, wantErr) {
+					t.Errorf("Decode(%q) error mismatch:\ngot  %v\nwant %v", tt.in, gotErr, wantErr)
+				}
+				if _, err := d.Decode(); !reflect.DeepEqual(err, gotErr) {
+					t.Errorf("Decode(%q) error is not sticky:\ngot  %v\nwant %v", tt.in, err, gotErr)
+				}
+			})
+		}
+	}
+}
+
+func TestDecoderInteractive(t *testing.T) {
+	// Each value is returned once its line is complete,
+	// without waiting for the start of the next value.
+	r, w := io.Pipe()
+	d := NewDecoder(r)
+	for _, line := range []string{"{\"k\": 1} // comment\n", "[\n\t1,\n]\n", "\"string\"\n", "123\n"} {
+		go w.Write([]byte(line))
+		v, err := d.Decode()
+		if err != nil {
+			t.Fatalf("Decode error: %v", err)
+		}
+		if got := v.String(); got != line {
+			t.Errorf("Decode = %q, want %q", got, line)
+		}
+	}
+	w.Close()
+	if _, err := d.Decode(); err != io.EOF {
+		t.Errorf("Decode error = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestDecoderParity(t *testing.T) {
+	for _, tt := range testdata {
+		if _, err := Parse([]byte(tt.in)); err != nil {
+			continue
+		}
+		d := NewDecoder(iotest.OneByteReader(strings.NewReader(tt.in)))
+		gotVal, gotErr := d.Decode()
+		if gotErr != nil {
+			t.Errorf("Decode(%q) error: %v", tt.in, gotErr)
+			continue
+		}
+		// Whitespace and comments after the line on which the value ends
+		// are not part of the decoded value.
+		n := d.InputOffset()
+		if !Extra(tt.in[n:]).IsValid() {
+			t.Errorf("Decode(%q) stopped within the value at offset %d", tt.in, n)
+			continue
+		}
+		wantVal, _ := Parse([]byte(tt.in[:n]))
+		if diff := cmp.Diff(wantVal, gotVal, cmpopts.EquateEmpty()); diff != "" {
+			t.Errorf("Decode(%q) mismatch (-want +got):\n%s", tt.in, diff)
+		}
+	}
+}
+
+func TestDecoderAliasing(t *testing.T) {
+	in := bytes.Repeat([]byte(`{"key":"value"} `), 1000)
+	d := NewDecoder(bytes.NewReader(in))
+	var vals []Value
+	for {
+		v, err := d.Decode()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Decode error: %v", err)
+		}
+		vals = append(vals, v)
+	}
+	var got []byte
+	for _, v := range vals {
+		got = v.append(got)
+	}
+	if !bytes.Equal(got, in) {
+		t.Errorf("concatenated values mismatch:\n%s", cmp.Diff(in, got))
+	}
+}
+
+func TestDecoderLarge(t *testing.T) {
+	// Decoding a large value one byte at a time must not be quadratic.
+	var b []byte
+	b = append(b, "// comment\n[\n"...)
+	for i := range 20000 {
+		b = fmt.Appendf(b, "\t{\"key\": \"value %d\", /* comment */ \"num\": %d},\n", i, i)
+	}
+	b = append(b, "] 12345 "...)
+	d := NewDecoder(iotest.OneByteReader(bytes.NewReader(b)))
+	v, err := d.Decode()
+	if err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if got, want := v.String(), string(b[:len(b)-len("12345 ")]); got != want {
+		t.Errorf("Decode mismatch: got %d bytes, want %d bytes", len(got), len(want))
+	}
+	v, err = d.Decode()
+	if err != nil || v.String() != "12345 " {
+		t.Errorf("Decode = (%q, %v), want (%q, nil)", v.String(), err, "12345 ")
+	}
+	if _, err := d.Decode(); err != io.EOF {
+		t.Errorf("Decode error = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestDecoderWithOptions(t *testing.T) {
+	decodeAll := func(d *Decoder) (vals []string, err error) {
+		for {
+			v, err := d.Decode()
+			if err != nil {
+				return vals, err
+			}
+			vals = append(vals, v.String())
+		}
+	}
+
+	tests := []struct {
+		in       string
+		opts     ParseOptions
+		wantVals []string
+		wantErr  error
+	}{{
+		in:       `[1,2] [3,4,5,6]`,
+		opts:     ParseOptions{MaxBytes: 6},
+		wantVals: []string{`[1,2] `},
+		wantErr:  &LimitError{Limit: "MaxBytes", Max: 6, Offset: 12, Line: 1, Column: 13},
+	}, {
+		in:       "[1,2]\n[3,4] // comment",
+		opts:     ParseOptions{MaxBytes: 6},
+		wantVals: []string{"[1,2]\n"},
+		wantErr:  &LimitError{Limit: "MaxBytes", Max: 6, Offset: 12, Line: 2, Column: 7},
+	}, {
+		in:       "[1,2]\n[3,4] ",
+		opts:     ParseOptions{MaxBytes: 6},
+		wantVals: []string{"[1,2]\n", "[3,4] "},
+		wantErr:  io.EOF,
+	}, {
+		in:       "[[1]]\n[[[1]]]",
+		opts:     ParseOptions{MaxDepth: 2},
+		wantVals: []string{"[[1]]\n"},
+		wantErr:  &LimitError{Limit: "MaxDepth", Max: 2, Offset: 8, Line: 2, Column: 3},
+	}, {
+		in:       "{\"a\":1}\n{\"b\":1,\n\"b\":2}",
+		opts:     ParseOptions{RejectDuplicateNames: true},
+		wantVals: []string{"{\"a\":1}\n"},
+		wantErr:  &SyntaxError{Offset: 16, Line: 3, Column: 1, Char: '"', Expected: "unique object name", Err: errors.New(`duplicate object name "b" (first at line 2, column 2)`)},
+	}}
+	for _, tt := range tests {
+		d := NewDecoderWithOptions(iotest.OneByteReader(strings.NewReader(tt.in)), tt.opts)
+		gotVals, gotErr := decodeAll(d)
+		if !reflect.DeepEqual(gotVals, tt.wantVals) {
+			t.Errorf("Decode(%q) values = %q, want %q", tt.in, gotVals, tt.wantVals)
+		}
+		if !reflect.DeepEqual(gotErr, tt.wantErr) {
+			t.Errorf("Decode(%q) error = %v, want %v", tt.in, gotErr, tt.wantErr)
+		}
+		if _, err := d.Decode(); !reflect.DeepEqual(err, tt.wantErr) {
+			t.Errorf("Decode(%q) subsequent error = %v, want %v", tt.in, err, tt.wantErr)
+		}
+	}
+
+	// The input buffered for a single value is bounded by MaxBytes.
+	const maxBytes = 1 << 16
+	r := &countingReader{r: io.MultiReader(strings.NewReader("["), repeatReader(' '))}
+	d := NewDecoderWithOptions(r, ParseOptions{MaxBytes: maxBytes})
+	var le *LimitError
+	if _, err := d.Decode(); !errors.As(err, &le) || le.Limit != "MaxBytes" {
+		t.Fatalf("Decode error = %v, want MaxBytes LimitError", err)
+	}
+	if r.n > 4*maxBytes {
+		t.Errorf("Decode read %d bytes, want at most %d", r.n, 4*maxBytes)
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.n += n
+	return n, err
+}
+
+// repeatReader endlessly repeats a single byte.
+type repeatReader byte
+
+func (r repeatReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = byte(r)
+	}
+	return len(b), nil
+}
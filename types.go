@@ -17,19 +17,45 @@
 // The Parse function parses HuJSON input as a Value,
 // which is a syntax tree exactly representing the input.
 // Comments and whitespace are represented using the Extra type.
+// The comments associated with a value, object member, or array element
+// can be read and replaced using the LeadingComments, TrailingComments,
+// SetLeadingComment, and SetTrailingComment methods.
+// The individual comments within an Extra can be iterated over using
+// Extra.Comments and an Extra can be constructed using
+// Extra.AppendComment and Extra.AppendWhitespace.
 // Composite types in JSON are represented using the Object and Array types.
 // Primitive types in JSON are represented using the Literal type.
 // The Value.Pack method serializes the syntax tree as raw output,
 // which is byte-for-byte identical to the input if no transformations
 // were performed on the value.
+// The ParseWithRecovery function reports every syntax error in the input
+// along with a best-effort Value, rather than stopping at the first error.
+// The ParseWithOptions function parses with additional restrictions,
+// such as limits on resource consumption for untrusted input,
+// rejecting objects with duplicate names, or rejecting invalid Unicode,
+// which are otherwise reported by the DuplicateNames and Validate methods.
+// The CheckIJSON method reports every way in which a value does not
+// conform to I-JSON (RFC 7493).
+// The Decoder type parses a sequence of HuJSON values from an io.Reader,
+// optionally with the same restrictions as ParseWithOptions.
 //
-// A HuJSON value can be transformed using the Minimize, Standardize, Format,
-// or Patch methods. Each of these methods mutate the value in place.
+// A HuJSON value can be transformed using the Minimize, Standardize,
+// Canonicalize, Format, or Patch methods.
+// Each of these methods mutate the value in place.
 // Call the Clone method beforehand in order to preserve the original value.
 // The Minimize and Standardize methods coerces HuJSON into standard JSON.
+// The Canonicalize method coerces HuJSON into canonical JSON (RFC 8785).
 // The Format method formats the value; it is similar to `go fmt`,
 // but instead for the HuJSON and standard JSON format.
 // The Patch method applies a JSON Patch (RFC 6902) to the receiving value.
+// The MergePatch method applies a JSON Merge Patch (RFC 7386) instead.
+// The Diff function computes a JSON Patch between two values.
+// The Find method locates a value by JSON Pointer (RFC 6901), while
+// the Query method locates values by JSONPath expression (RFC 9535).
+// The SortObjects method sorts object members and the Array.Sort method
+// sorts array elements while keeping comments attached.
+// The UpdateFrom method updates the receiving value to match a Go value
+// while preserving comments on unchanged members and elements.
 //
 // # Grammar
 //
@@ -86,6 +112,10 @@
 //	if err := json.Unmarshal(b, &v); err != nil {
 //		... // handle err
 //	}
+//
+// Alternatively, the Unmarshal function directly unmarshals HuJSON
+// into a Go value, following the same rules as json.Unmarshal,
+// while reporting the position of any value that could not be unmarshaled.
 package hujson
 
 import (
@@ -94,6 +124,8 @@ import (
 	"fmt"
 	"iter"
 	"math"
+	"math/big"
+	"slices"
 	"strconv"
 	"unicode/utf8"
 )
@@ -139,9 +171,30 @@ type Value struct {
 
 // Clone returns a deep copy of the value.
 func (v Value) Clone() Value {
-	v.BeforeExtra = copyBytes(v.BeforeExtra)
-	v.Value = v.Value.clone()
-	v.AfterExtra = copyBytes(v.AfterExtra)
+	// Each object or array is shallow copied before its members or elements
+	// are visited, such that only the copy is modified.
+	for v2 := range v.All() {
+		v2.BeforeExtra = copyBytes(v2.BeforeExtra)
+		switch v3 := v2.Value.(type) {
+		case Literal:
+			v2.Value = Literal(copyBytes(v3))
+		case *Object:
+			obj := *v3
+			if obj.Members != nil {
+				obj.Members = append([]ObjectMember(nil), obj.Members...)
+			}
+			obj.AfterExtra = copyBytes(obj.AfterExtra)
+			v2.Value = &obj
+		case *Array:
+			arr := *v3
+			if arr.Elements != nil {
+				arr.Elements = append([]Value(nil), arr.Elements...)
+			}
+			arr.AfterExtra = copyBytes(arr.AfterExtra)
+			v2.Value = &arr
+		}
+		v2.AfterExtra = copyBytes(v2.AfterExtra)
+	}
 	return v
 }
 
@@ -163,25 +216,154 @@ func (v *Value) Range(f func(v *Value) bool) bool {
 // starting with v itself.
 func (v *Value) All() iter.Seq[*Value] {
 	return func(yield func(*Value) bool) {
-		v.walkAll(yield)
+		// Use an explicit stack rather than recursion so that
+		// deeply nested values do not grow the goroutine stack.
+		stack := []*Value{v}
+		for len(stack) > 0 {
+			v := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if !yield(v) {
+				return
+			}
+			if comp, ok := v.Value.(composite); ok {
+				for i := numValues(comp) - 1; i >= 0; i-- {
+					stack = append(stack, valueAt(comp, i))
+				}
+			}
+		}
 	}
 }
 
-// walkAll recursively yields v and its descendants in depth-first order.
-func (v *Value) walkAll(yield func(*Value) bool) bool {
-	if !yield(v) {
-		return false
+// Path describes the location of a value yielded by Walk.
+type Path struct {
+	// Pointer is the JSON pointer (RFC 6901) to the value.
+	Pointer string
+	// Parent is the *Object or *Array that directly contains the value.
+	// It is nil for the root value.
+	Parent ValueTrimmed
+	// Name is the member name if Parent is an *Object.
+	Name string
+	// Index is the index of the member or element within Parent.
+	// It is -1 for the root value.
+	Index int
+	// Depth is the number of ancestors of the value,
+	// where the root value has a depth of zero.
+	Depth int
+
+	walk *walkState
+	gen  int // value of walk.gen when this path was yielded
+}
+
+// walkState is the state shared by all paths yielded by a single Walk.
+type walkState struct {
+	gen  int  // incremented for each value yielded
+	skip bool // whether to skip the children of the current value
+}
+
+// SkipChildren causes Walk to skip the children of the current value.
+// It has no effect if called after iteration has advanced past the value.
+func (p Path) SkipChildren() {
+	if p.walk != nil && p.walk.gen == p.gen {
+		p.walk.skip = true
 	}
+}
 
-	if comp, ok := v.Value.(composite); ok {
-		for v2 := range comp.allValues() {
-			if !v2.walkAll(yield) {
-				return false
+// Walk returns an iterator over all values in depth-first order,
+// starting with v itself, along with the path to each value.
+// Unlike All, it does not yield object member names.
+// Calling Path.SkipChildren during iteration skips the
+// children of the value most recently yielded.
+func (v *Value) Walk() iter.Seq2[Path, *Value] {
+	return func(yield func(Path, *Value) bool) {
+		type entry struct {
+			p Path
+			v *Value
+		}
+		walk := new(walkState)
+		stack := []entry{{Path{Index: -1, walk: walk}, v}}
+		for len(stack) > 0 {
+			e := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			walk.gen++
+			walk.skip = false
+			e.p.gen = walk.gen
+			if !yield(e.p, e.v) {
+				return
+			}
+			if walk.skip {
+				continue
+			}
+
+			// Push the children in reverse order so that they are yielded in order.
+			child := Path{Parent: e.v.Value, Depth: e.p.Depth + 1, walk: walk}
+			switch comp := e.v.Value.(type) {
+			case *Object:
+				for i := len(comp.Members) - 1; i >= 0; i-- {
+					child.Name = comp.Members[i].Name.Value.(Literal).String()
+					child.Index = i
+					child.Pointer = string(appendPointerToken([]byte(e.p.Pointer), child.Name))
+					stack = append(stack, entry{child, &comp.Members[i].Value})
+				}
+			case *Array:
+				for i := len(comp.Elements) - 1; i >= 0; i-- {
+					child.Index = i
+					child.Pointer = string(appendPointerIndex([]byte(e.p.Pointer), i))
+					stack = append(stack, entry{child, &comp.Elements[i]})
+				}
 			}
 		}
 	}
+}
 
-	return true
+// walkPointers returns an iterator over all values in depth-first order,
+// starting with v itself, along with the JSON pointer to each value
+// appended to ptr. Unlike Walk, the pointer is built in a single buffer,
+// which is only valid until the next iteration.
+// The yielded values may be modified, but not the number of
+// members or elements in any object or array.
+func (v *Value) walkPointers(ptr []byte) iter.Seq2[[]byte, *Value] {
+	return func(yield func([]byte, *Value) bool) {
+		type frame struct {
+			comp   composite
+			next   int // index of the next member or element to visit
+			ptrLen int // length of the pointer to comp
+		}
+		var stack []frame
+		visit := func(ptr []byte, v *Value) bool {
+			if !yield(ptr, v) {
+				return false
+			}
+			if comp, ok := v.Value.(composite); ok {
+				stack = append(stack, frame{comp, 0, len(ptr)})
+			}
+			return true
+		}
+		ptr = slices.Clip(ptr)
+		if !visit(ptr, v) {
+			return
+		}
+		for len(stack) > 0 {
+			f := &stack[len(stack)-1]
+			if f.next == f.comp.length() {
+				stack = stack[:len(stack)-1]
+				continue
+			}
+			i := f.next
+			f.next++
+			var child *Value
+			switch comp := f.comp.(type) {
+			case *Object:
+				ptr = appendPointerToken(ptr[:f.ptrLen], comp.Members[i].Name.Value.(Literal).String())
+				child = &comp.Members[i].Value
+			case *Array:
+				ptr = appendPointerIndex(ptr[:f.ptrLen], i)
+				child = &comp.Elements[i]
+			}
+			if !visit(ptr, child) {
+				return
+			}
+		}
+	}
 }
 
 // ValueTrimmed is a JSON value without surrounding whitespace or comments.
@@ -277,6 +459,63 @@ func Float(v float64) Literal {
 	}
 }
 
+// BigInt constructs a JSON literal for an arbitrary-precision integer.
+// A nil value is represented as a JSON null.
+func BigInt(v *big.Int) Literal {
+	if v == nil {
+		return Literal("null")
+	}
+	return Literal(v.Append(nil, 10))
+}
+
+// BigFloat constructs a JSON literal for an arbitrary-precision
+// floating-point number using the shortest decimal representation that
+// is exactly parsed as v at the precision of v.
+// The values +Inf and -Inf will be represented as a JSON string
+// with the values "Infinity" and "-Infinity".
+// A nil value is represented as a JSON null.
+func BigFloat(v *big.Float) Literal {
+	switch {
+	case v == nil:
+		return Literal("null")
+	case v.IsInf() && v.Signbit():
+		return Literal(`"-Infinity"`)
+	case v.IsInf():
+		return Literal(`"Infinity"`)
+	default:
+		return Literal(v.Append(nil, 'g', -1))
+	}
+}
+
+// Rat constructs a JSON literal for a rational number.
+// It is represented exactly if it has a finite decimal representation,
+// otherwise it is rounded to the nearest float64 (see Float).
+// A nil value is represented as a JSON null.
+func Rat(v *big.Rat) Literal {
+	if v == nil {
+		return Literal("null")
+	}
+	if n, exact := v.FloatPrec(); exact {
+		return Literal(v.FloatString(n))
+	}
+	f, _ := v.Float64()
+	return Float(f)
+}
+
+// Number constructs a JSON literal for a json.Number.
+// An empty number is represented as 0, consistent with json.Marshal.
+// An invalid number is represented as a JSON string (see String).
+func Number(v json.Number) Literal {
+	switch b := Literal(v); {
+	case len(b) == 0:
+		return Literal("0")
+	case b.Kind() != '0' || !b.IsValid():
+		return String(string(v))
+	default:
+		return Literal(copyBytes(b))
+	}
+}
+
 func (b Literal) clone() ValueTrimmed {
 	return Literal(copyBytes(b))
 }
@@ -360,6 +599,76 @@ func (b Literal) Float() (n float64) {
 	return 0
 }
 
+// isNumber reports whether b is a valid JSON number.
+func (b Literal) isNumber() bool {
+	return b.Kind() == '0' && b.IsValid()
+}
+
+// BigInt returns the integer value for a JSON number
+// as an arbitrary-precision integer.
+// It reports false if the literal is not a JSON number
+// without a fraction or exponent.
+func (b Literal) BigInt() (*big.Int, bool) {
+	if !b.isNumber() || bytes.ContainsAny(b, ".eE") {
+		return nil, false
+	}
+	return new(big.Int).SetString(string(b), 10)
+}
+
+// BigFloat returns the value for a JSON number
+// as an arbitrary-precision floating-point number.
+// Its precision is at least 64 bits and otherwise large enough to
+// preserve every significant digit of the number, such that
+// formatting the result with BigFloat reproduces the same number.
+// It returns +Inf or -Inf for any JSON string with the values
+// "Infinity" or "-Infinity".
+// It reports false for all other cases, including numbers whose magnitude
+// is too large for big.Float (numbers too small are rounded to zero).
+func (b Literal) BigFloat() (*big.Float, bool) {
+	switch {
+	case b.isNumber():
+		var digits int
+		for _, c := range b {
+			if c == 'e' || c == 'E' {
+				break
+			}
+			if '0' <= c && c <= '9' {
+				digits++
+			}
+		}
+		prec := max(64, uint(math.Ceil(float64(digits)*math.Log2(10)))+2)
+		f, _, err := big.ParseFloat(string(b), 10, prec, big.ToNearestEven)
+		return f, err == nil && !f.IsInf()
+	case b.Kind() == '"':
+		switch b.String() {
+		case "Infinity":
+			return new(big.Float).SetInf(false), true
+		case "-Infinity":
+			return new(big.Float).SetInf(true), true
+		}
+	}
+	return nil, false
+}
+
+// Rat returns the exact value for a JSON number as a rational number.
+// It reports false if the literal is not a JSON number
+// or has an exponent too large for big.Rat.
+func (b Literal) Rat() (*big.Rat, bool) {
+	if !b.isNumber() {
+		return nil, false
+	}
+	return new(big.Rat).SetString(string(b))
+}
+
+// Number returns the raw representation of a JSON number as a json.Number.
+// It reports false if the literal is not a JSON number.
+func (b Literal) Number() (json.Number, bool) {
+	if !b.isNumber() {
+		return "", false
+	}
+	return json.Number(b), true
+}
+
 func (Literal) isValueTrimmed() {}
 
 // Object is an exact syntactic representation of a JSON object.
@@ -422,15 +731,7 @@ func (obj *Object) afterExtra() *Extra {
 }
 
 func (obj Object) clone() ValueTrimmed {
-	if obj.Members != nil {
-		obj.Members = append([]ObjectMember(nil), obj.Members...)
-		for i := range obj.Members {
-			obj.Members[i].Name = obj.Members[i].Name.Clone()
-			obj.Members[i].Value = obj.Members[i].Value.Clone()
-		}
-	}
-	obj.AfterExtra = copyBytes(obj.AfterExtra)
-	return &obj
+	return Value{Value: &obj}.Clone().Value
 }
 
 func (Object) Kind() Kind { return '{' }
@@ -491,14 +792,7 @@ func (arr *Array) afterExtra() *Extra {
 }
 
 func (arr Array) clone() ValueTrimmed {
-	if arr.Elements != nil {
-		arr.Elements = append([]Value(nil), arr.Elements...)
-		for i := range arr.Elements {
-			arr.Elements[i] = arr.Elements[i].Clone()
-		}
-	}
-	arr.AfterExtra = copyBytes(arr.AfterExtra)
-	return &arr
+	return Value{Value: &arr}.Clone().Value
 }
 
 func (Array) Kind() Kind { return '[' }
@@ -541,6 +835,32 @@ func setTrailingComma(comp composite, v bool) {
 	}
 }
 
+// numValues reports the number of values in comp,
+// where each object member has a name and a value.
+func numValues(comp composite) int {
+	switch comp := comp.(type) {
+	case *Object:
+		return 2 * len(comp.Members)
+	case *Array:
+		return len(comp.Elements)
+	}
+	return 0
+}
+
+// valueAt returns the i-th value in comp in the order of allValues.
+func valueAt(comp composite, i int) *Value {
+	switch comp := comp.(type) {
+	case *Object:
+		if i%2 == 0 {
+			return &comp.Members[i/2].Name
+		}
+		return &comp.Members[i/2].Value
+	case *Array:
+		return &comp.Elements[i]
+	}
+	return nil
+}
+
 var (
 	_ composite = (*Object)(nil)
 	_ composite = (*Array)(nil)
@@ -1,6 +1,11 @@
 package hujson
 
 import (
+	"encoding/json"
+	"math/big"
+	"runtime/debug"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -36,6 +41,66 @@ func TestAll(t *testing.T) {
 	}
 }
 
+func TestWalk(t *testing.T) {
+	v, err := Parse([]byte(`["fizz", {"key": ["value", {"foo": "bar"}], "a/b": 0}, [1,2,3], "buzz"]`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	type entry struct {
+		Pointer    string
+		Name       string
+		Index      int
+		Depth      int
+		ParentKind Kind
+		Value      string
+	}
+	var got []entry
+	for p, v2 := range v.Walk() {
+		if p.Pointer == "/2" {
+			p.SkipChildren()
+		}
+		if v.Find(p.Pointer) != v2 {
+			t.Errorf("Find(%q) does not match the walked value", p.Pointer)
+		}
+		var parentKind Kind
+		if p.Parent != nil {
+			parentKind = p.Parent.Kind()
+		}
+		got = append(got, entry{p.Pointer, p.Name, p.Index, p.Depth, parentKind, v2.String()})
+	}
+	want := []entry{
+		{"", "", -1, 0, 0, `["fizz", {"key": ["value", {"foo": "bar"}], "a/b": 0}, [1,2,3], "buzz"]`},
+		{"/0", "", 0, 1, '[', `"fizz"`},
+		{"/1", "", 1, 1, '[', ` {"key": ["value", {"foo": "bar"}], "a/b": 0}`},
+		{"/1/key", "key", 0, 2, '{', ` ["value", {"foo": "bar"}]`},
+		{"/1/key/0", "", 0, 3, '[', `"value"`},
+		{"/1/key/1", "", 1, 3, '[', ` {"foo": "bar"}`},
+		{"/1/key/1/foo", "foo", 0, 4, '{', ` "bar"`},
+		{"/1/a~1b", "a/b", 1, 2, '{', ` 0`},
+		{"/2", "", 2, 1, '[', ` [1,2,3]`},
+		{"/3", "", 3, 1, '[', ` "buzz"`},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	// Calling SkipChildren on a previously yielded path has no effect.
+	var saved Path
+	var pointers []string
+	for p := range v.Walk() {
+		switch p.Pointer {
+		case "/1/key/0":
+			saved = p
+		case "/1/key/1":
+			saved.SkipChildren()
+		}
+		pointers = append(pointers, p.Pointer)
+	}
+	if !slices.Contains(pointers, "/1/key/1/foo") {
+		t.Errorf("SkipChildren on a stale path skipped the children of the current value")
+	}
+}
+
 func BenchmarkAll(b *testing.B) {
 	v, err := Parse([]byte(`["fizz", {"key": ["value", {"foo": "bar"}]}, [1,2,3], "buzz"]`))
 	if err != nil {
@@ -50,3 +115,247 @@ func BenchmarkAll(b *testing.B) {
 		}
 	}
 }
+
+// benchmarkInputs are inputs for benchmarks of the core operations,
+// which include a typical configuration file and a deeply nested value.
+var benchmarkInputs = []struct {
+	name string
+	in   []byte
+}{
+	{"Typical", []byte(`// Comment
+{
+	"fizz": ["buzz", 1, 2.5, true, false, null], // Comment
+	"key": {"value": {"foo": "bar"}, "list": [1, 2, 3]},
+	/* Comment */
+	"hello": "world",
+}`)},
+	{"Deep", []byte(strings.Repeat(`[{"k":`, 1000) + "null" + strings.Repeat("}]", 1000))},
+}
+
+func BenchmarkParse(b *testing.B) {
+	for _, bb := range benchmarkInputs {
+		b.Run(bb.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(bb.in)))
+			for b.Loop() {
+				if _, err := Parse(bb.in); err != nil {
+					b.Fatalf("Parse: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPack(b *testing.B) {
+	for _, bb := range benchmarkInputs {
+		b.Run(bb.name, func(b *testing.B) {
+			v, err := Parse(bb.in)
+			if err != nil {
+				b.Fatalf("Parse: %v", err)
+			}
+			b.ReportAllocs()
+			b.SetBytes(int64(len(bb.in)))
+			for b.Loop() {
+				v.Pack()
+			}
+		})
+	}
+}
+
+func BenchmarkUpdateOffsets(b *testing.B) {
+	for _, bb := range benchmarkInputs {
+		b.Run(bb.name, func(b *testing.B) {
+			v, err := Parse(bb.in)
+			if err != nil {
+				b.Fatalf("Parse: %v", err)
+			}
+			b.ReportAllocs()
+			for b.Loop() {
+				v.UpdateOffsets()
+			}
+		})
+	}
+}
+
+func BenchmarkFormat(b *testing.B) {
+	for _, bb := range benchmarkInputs {
+		b.Run(bb.name, func(b *testing.B) {
+			v, err := Parse(bb.in)
+			if err != nil {
+				b.Fatalf("Parse: %v", err)
+			}
+			b.ReportAllocs()
+			for b.Loop() {
+				v2 := v.Clone()
+				v2.Format()
+			}
+		})
+	}
+}
+
+func TestDeepNesting(t *testing.T) {
+	// Limit the stack size to verify that the stack does not grow with depth.
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+	const depth = 100000
+	in := strings.Repeat(`[{"k":`, depth) + "null" + strings.Repeat("}]", depth)
+	v, err := Parse([]byte(in))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if got := v.Pack(); string(got) != in {
+		t.Errorf("Pack mismatch")
+	}
+	v.UpdateOffsets()
+	if v.EndOffset != len(in) {
+		t.Errorf("UpdateOffsets: EndOffset = %d, want %d", v.EndOffset, len(in))
+	}
+	var n int
+	for range v.All() {
+		n++
+	}
+	if want := 3*depth + 1; n != want {
+		t.Errorf("All yielded %d values, want %d", n, want)
+	}
+
+	v2 := v.Clone()
+	if !v2.IsStandard() {
+		t.Errorf("IsStandard = false, want true")
+	}
+	v2.Standardize()
+	v2.Minimize()
+	if got := v2.Pack(); string(got) != in {
+		t.Errorf("Minimize mismatch")
+	}
+	// Disable the line width limit to avoid a quadratic amount of indentation.
+	v2.FormatWithOptions(FormatOptions{MaxLineWidth: -1})
+	if got, want := v2.Pack(), strings.ReplaceAll(in, ":", ": ")+"\n"; string(got) != want {
+		t.Errorf("Format mismatch")
+	}
+	if got := v.Pack(); string(got) != in {
+		t.Errorf("original value modified")
+	}
+
+	v2 = v.Clone()
+	if err := v2.Canonicalize(); err != nil || string(v2.Pack()) != in {
+		t.Errorf("Canonicalize = %v, want unchanged value", err)
+	}
+	v2.SortObjects(nil, true)
+	v2.SortMarkedArrays()
+	if got := v2.Pack(); string(got) != in {
+		t.Errorf("SortObjects or SortMarkedArrays mismatch")
+	}
+	results, err := v.Query(`$..[?@ == null]`)
+	if err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	n = 0
+	for range results {
+		n++
+	}
+	if n != 1 {
+		t.Errorf("Query yielded %d values, want 1", n)
+	}
+
+	patch := strings.Repeat(`{"k":[`, depth) + `{"k":1}` + strings.Repeat("]}", depth)
+	v2 = Value{Value: &Object{}}
+	for range 2 {
+		if err := v2.MergePatch([]byte(patch)); err != nil {
+			t.Fatalf("MergePatch error: %v", err)
+		}
+	}
+	if got := v2.Pack(); string(got) != patch {
+		t.Errorf("MergePatch mismatch")
+	}
+
+	// Diff minimizes every nested array element to compare them,
+	// so use a smaller depth to avoid a quadratic amount of work.
+	const diffDepth = 1000
+	from, err := Parse([]byte(strings.Repeat(`[{"k":`, diffDepth) + "null" + strings.Repeat("}]", diffDepth)))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	to, err := Parse([]byte(strings.Repeat(`[{"k":`, diffDepth) + "1" + strings.Repeat("}]", diffDepth)))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	diff, err := Diff(from, to)
+	if err != nil {
+		t.Fatalf("Diff error: %v", err)
+	}
+	if err := from.Patch(diff); err != nil || string(from.Pack()) != string(to.Pack()) {
+		t.Errorf("Patch(Diff) = %v, want value equal to to", err)
+	}
+}
+
+func TestBigNumbers(t *testing.T) {
+	tests := []struct {
+		in       string
+		wantInt  string // empty if not an integer
+		wantRat  string // empty if not a number
+		wantBigF string // empty if not a number
+	}{
+		{in: `0`, wantInt: "0", wantRat: "0", wantBigF: `0`},
+		{in: `-0`, wantInt: "0", wantRat: "0", wantBigF: `-0`},
+		{in: `12345678901234567890123456789`, wantInt: "12345678901234567890123456789", wantRat: "12345678901234567890123456789", wantBigF: `1.2345678901234567890123456789e+28`},
+		{in: `-9007199254740993`, wantInt: "-9007199254740993", wantRat: "-9007199254740993", wantBigF: `-9.007199254740993e+15`},
+		{in: `0.1`, wantRat: "1/10", wantBigF: `0.1`},
+		{in: `1234567890.123456789012345678`, wantRat: "617283945061728394506172839/500000000000000000", wantBigF: `1.234567890123456789012345678e+09`},
+		{in: `1.50E2`, wantRat: "150", wantBigF: `150`},
+		{in: `"Infinity"`, wantBigF: `"Infinity"`},
+		{in: `"-Infinity"`, wantBigF: `"-Infinity"`},
+		{in: `"123"`},
+		{in: `null`},
+		{in: `1e1000000000`},
+	}
+	for _, tt := range tests {
+		lit := Literal(tt.in)
+		n, ok := lit.BigInt()
+		if ok != (tt.wantInt != "") || ok && n.String() != tt.wantInt {
+			t.Errorf("Literal(%s).BigInt() = (%v, %v), want %v", tt.in, n, ok, tt.wantInt)
+		}
+		if ok && string(BigInt(n)) != n.String() {
+			t.Errorf("BigInt(%v) = %s", n, BigInt(n))
+		}
+
+		r, ok := lit.Rat()
+		if ok != (tt.wantRat != "") || ok && r.RatString() != tt.wantRat {
+			t.Errorf("Literal(%s).Rat() = (%v, %v), want %v", tt.in, r, ok, tt.wantRat)
+		}
+		if ok {
+			if r2, _ := Rat(r).Rat(); r2.Cmp(r) != 0 {
+				t.Errorf("Rat(%v) = %s, want exact round-trip", r, Rat(r))
+			}
+		}
+
+		f, ok := lit.BigFloat()
+		var got Literal
+		if ok {
+			got = BigFloat(f)
+		}
+		if ok != (tt.wantBigF != "") || string(got) != tt.wantBigF {
+			t.Errorf("BigFloat(Literal(%s).BigFloat()) = (%s, %v), want %s", tt.in, got, ok, tt.wantBigF)
+		}
+
+		num, ok := lit.Number()
+		if ok != (lit.Kind() == '0') || ok && string(Number(num)) != tt.in {
+			t.Errorf("Literal(%s).Number() = (%v, %v)", tt.in, num, ok)
+		}
+	}
+
+	if got := Rat(big.NewRat(-1, 3)); string(got) != `-0.3333333333333333` {
+		t.Errorf("Rat(-1/3) = %s, want -0.3333333333333333", got)
+	}
+	for _, tt := range []struct {
+		in   json.Number
+		want string
+	}{{"", `0`}, {"1e3", `1e3`}, {"0x10", `"0x10"`}} {
+		if got := Number(tt.in); string(got) != tt.want {
+			t.Errorf("Number(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+	for _, got := range []Literal{BigInt(nil), BigFloat(nil), Rat(nil)} {
+		if string(got) != "null" {
+			t.Errorf("nil constructor = %s, want null", got)
+		}
+	}
+}
@@ -0,0 +1,610 @@
+// Copyright (c) 2021 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hujson
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Unmarshal parses the HuJSON input and stores the result in the value
+// pointed to by v. It is equivalent to calling Standardize followed by
+// json.Unmarshal, but avoids copying the input and reports the position
+// of any value that cannot be stored in v.
+//
+// Go values are populated according to the same rules as json.Unmarshal,
+// including the handling of struct field tags, json.Unmarshaler,
+// encoding.TextUnmarshaler, and json.Number.
+// Unlike json.Unmarshal, unmarshaling stops at the first error.
+// Syntax errors are reported as a *SyntaxError, while errors populating
+// a Go value are reported as an *UnmarshalError.
+func Unmarshal(b []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("hujson: Unmarshal(non-pointer or nil %T)", v)
+	}
+	ast, err := Parse(b)
+	if err != nil {
+		return err
+	}
+	u := unmarshaler{input: b}
+	return u.unmarshal(&ast, rv.Elem())
+}
+
+// UnmarshalError describes a HuJSON value that could not be
+// stored in a Go value.
+type UnmarshalError struct {
+	// Offset is the byte offset within the input of the offending value.
+	Offset int64
+	// Line and Column are the 1-based line and column of Offset,
+	// where the column is counted in bytes.
+	Line, Column int
+	// Pointer is the JSON pointer (RFC 6901) to the offending value.
+	Pointer string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *UnmarshalError) Error() string {
+	if e.Pointer == "" {
+		return fmt.Sprintf("hujson: line %d, column %d: %v", e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("hujson: line %d, column %d at %s: %v", e.Line, e.Column, e.Pointer, e.Err)
+}
+
+func (e *UnmarshalError) Unwrap() error {
+	return e.Err
+}
+
+// maxUnmarshalDepth is the maximum nesting depth of objects and arrays
+// that Unmarshal stores into Go values, matching json.Unmarshal.
+const maxUnmarshalDepth = 10000
+
+type unmarshaler struct {
+	input   []byte
+	pointer []byte // JSON pointer to the current value
+	depth   int    // nesting depth of the current value
+}
+
+func (u *unmarshaler) errorf(v *Value, format string, args ...any) error {
+	line, column := lineColumn(u.input, v.StartOffset)
+	return &UnmarshalError{
+		Offset:  int64(v.StartOffset),
+		Line:    line,
+		Column:  column,
+		Pointer: string(u.pointer),
+		Err:     fmt.Errorf(format, args...),
+	}
+}
+
+func (u *unmarshaler) typeError(v *Value, t reflect.Type) error {
+	what := kindName(v.Value.Kind())
+	if v.Value.Kind() == '0' {
+		what += " " + string(v.Value.(Literal))
+	}
+	return u.errorf(v, "cannot unmarshal %s into %v", what, t)
+}
+
+func kindName(k Kind) string {
+	switch k {
+	case 'n':
+		return "null"
+	case 'f', 't':
+		return "boolean"
+	case '"':
+		return "string"
+	case '0':
+		return "number"
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	default:
+		return "invalid"
+	}
+}
+
+var (
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	jsonNumberType      = reflect.TypeFor[json.Number]()
+)
+
+// indirect walks down pointers in rv, allocating as necessary,
+// until it finds a non-pointer or a value implementing one of the
+// unmarshal interfaces. If decodingNull, it stops at the last pointer
+// so that it may be set to nil.
+func indirect(rv reflect.Value, decodingNull bool) (json.Unmarshaler, encoding.TextUnmarshaler, reflect.Value) {
+	for {
+		// Load a non-nil pointer held by an interface.
+		if rv.Kind() == reflect.Interface && !rv.IsNil() {
+			if e := rv.Elem(); e.Kind() == reflect.Pointer && !e.IsNil() && !(decodingNull && e.Elem().Kind() == reflect.Pointer) {
+				rv = e
+				continue
+			}
+		}
+		if rv.Kind() != reflect.Pointer {
+			break
+		}
+		if decodingNull && rv.CanSet() {
+			break
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		if rv.Type().NumMethod() > 0 && rv.CanInterface() {
+			if u, ok := rv.Interface().(json.Unmarshaler); ok {
+				return u, nil, reflect.Value{}
+			}
+			if !decodingNull {
+				if u, ok := rv.Interface().(encoding.TextUnmarshaler); ok {
+					return nil, u, reflect.Value{}
+				}
+			}
+		}
+		rv = rv.Elem()
+	}
+	if rv.CanAddr() && rv.Addr().CanInterface() {
+		if u, ok := rv.Addr().Interface().(json.Unmarshaler); ok {
+			return u, nil, reflect.Value{}
+		}
+		if u, ok := rv.Addr().Interface().(encoding.TextUnmarshaler); ok && !decodingNull {
+			return nil, u, reflect.Value{}
+		}
+	}
+	return nil, nil, rv
+}
+
+func (u *unmarshaler) unmarshal(v *Value, rv reflect.Value) error {
+	isNull := v.Value.Kind() == 'n'
+	ju, tu, rv := indirect(rv, isNull)
+	switch {
+	case ju != nil:
+		v2 := v.Clone()
+		v2.Minimize()
+		if err := ju.UnmarshalJSON(v2.Pack()); err != nil {
+			return u.errorf(v, "%w", err)
+		}
+		return nil
+	case tu != nil:
+		if v.Value.Kind() != '"' {
+			return u.typeError(v, reflect.TypeOf(tu).Elem())
+		}
+		if err := tu.UnmarshalText([]byte(v.Value.(Literal).String())); err != nil {
+			return u.errorf(v, "%w", err)
+		}
+		return nil
+	}
+
+	if _, ok := v.Value.(composite); ok {
+		if u.depth >= maxUnmarshalDepth {
+			return u.errorf(v, "exceeded max depth of %d", maxUnmarshalDepth)
+		}
+		u.depth++
+		defer func() { u.depth-- }()
+	}
+	switch v2 := v.Value.(type) {
+	case Literal:
+		return u.unmarshalLiteral(v, v2, rv)
+	case *Object:
+		return u.unmarshalObject(v, v2, rv)
+	case *Array:
+		return u.unmarshalArray(v, v2, rv)
+	}
+	return nil
+}
+
+func (u *unmarshaler) unmarshalLiteral(v *Value, lit Literal, rv reflect.Value) error {
+	switch lit.Kind() {
+	case 'n':
+		switch rv.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice:
+			rv.SetZero()
+		}
+		return nil // null is a no-op for all other kinds
+	case 'f', 't':
+		switch {
+		case rv.Kind() == reflect.Bool:
+			rv.SetBool(lit.Bool())
+		case rv.Kind() == reflect.Interface && rv.NumMethod() == 0:
+			rv.Set(reflect.ValueOf(lit.Bool()))
+		default:
+			return u.typeError(v, rv.Type())
+		}
+	case '"':
+		s := lit.String()
+		switch {
+		case rv.Type() == jsonNumberType:
+			if !Literal(s).IsValid() || Literal(s).Kind() != '0' {
+				return u.errorf(v, "invalid number literal %q", s)
+			}
+			rv.SetString(s)
+		case rv.Kind() == reflect.String:
+			rv.SetString(s)
+		case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return u.errorf(v, "%w", err)
+			}
+			rv.SetBytes(b)
+		case rv.Kind() == reflect.Interface && rv.NumMethod() == 0:
+			rv.Set(reflect.ValueOf(s))
+		default:
+			return u.typeError(v, rv.Type())
+		}
+	case '0':
+		s := string(lit)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || rv.OverflowInt(n) {
+				return u.typeError(v, rv.Type())
+			}
+			rv.SetInt(n)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			n, err := strconv.ParseUint(s, 10, 64)
+			if err != nil || rv.OverflowUint(n) {
+				return u.typeError(v, rv.Type())
+			}
+			rv.SetUint(n)
+		case reflect.Float32, reflect.Float64:
+			n, err := strconv.ParseFloat(s, rv.Type().Bits())
+			if err != nil || rv.OverflowFloat(n) {
+				return u.typeError(v, rv.Type())
+			}
+			rv.SetFloat(n)
+		case reflect.String:
+			if rv.Type() != jsonNumberType {
+				return u.typeError(v, rv.Type())
+			}
+			rv.SetString(s)
+		case reflect.Interface:
+			if rv.NumMethod() > 0 {
+				return u.typeError(v, rv.Type())
+			}
+			n, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return u.typeError(v, rv.Type())
+			}
+			rv.Set(reflect.ValueOf(n))
+		default:
+			return u.typeError(v, rv.Type())
+		}
+	}
+	return nil
+}
+
+func (u *unmarshaler) unmarshalObject(v *Value, obj *Object, rv reflect.Value) error {
+	var fields structFields
+	switch rv.Kind() {
+	case reflect.Interface:
+		if rv.NumMethod() > 0 {
+			return u.typeError(v, rv.Type())
+		}
+		m := make(map[string]any, len(obj.Members))
+		mv := reflect.ValueOf(m)
+		if err := u.unmarshalObject(v, obj, mv); err != nil {
+			return err
+		}
+		rv.Set(mv)
+		return nil
+	case reflect.Map:
+		switch kt := rv.Type().Key(); {
+		case kt.Kind() == reflect.String,
+			reflect.PointerTo(kt).Implements(textUnmarshalerType),
+			kt.Kind() >= reflect.Int && kt.Kind() <= reflect.Uintptr:
+		default:
+			return u.typeError(v, rv.Type())
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMap(rv.Type()))
+		}
+	case reflect.Struct:
+		fields = cachedStructFields(rv.Type())
+	default:
+		return u.typeError(v, rv.Type())
+	}
+
+	for i := range obj.Members {
+		name := obj.Members[i].Name.Value.(Literal).String()
+		value := &obj.Members[i].Value
+		n := len(u.pointer)
+		u.pointer = appendPointerToken(u.pointer, name)
+		var err error
+		switch rv.Kind() {
+		case reflect.Map:
+			err = u.unmarshalMapEntry(&obj.Members[i].Name, name, value, rv)
+		case reflect.Struct:
+			if f := fields.lookup(name); f != nil {
+				var fv reflect.Value
+				if fv, err = fieldByIndex(rv, f.index); err != nil {
+					err = u.errorf(value, "%w", err)
+				} else if f.quoted {
+					err = u.unmarshalQuoted(value, fv)
+				} else {
+					err = u.unmarshal(value, fv)
+				}
+			}
+		}
+		u.pointer = u.pointer[:n]
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *unmarshaler) unmarshalMapEntry(nameValue *Value, name string, value *Value, rv reflect.Value) error {
+	kt := rv.Type().Key()
+	var kv reflect.Value
+	switch {
+	case reflect.PointerTo(kt).Implements(textUnmarshalerType):
+		kv = reflect.New(kt)
+		if err := kv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(name)); err != nil {
+			return u.errorf(nameValue, "%w", err)
+		}
+		kv = kv.Elem()
+	case kt.Kind() == reflect.String:
+		kv = reflect.ValueOf(name).Convert(kt)
+	default:
+		kv = reflect.New(kt).Elem()
+		switch kt.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(name, 10, 64)
+			if err != nil || kv.OverflowInt(n) {
+				return u.errorf(nameValue, "cannot unmarshal object name %q into %v", name, kt)
+			}
+			kv.SetInt(n)
+		default:
+			n, err := strconv.ParseUint(name, 10, 64)
+			if err != nil || kv.OverflowUint(n) {
+				return u.errorf(nameValue, "cannot unmarshal object name %q into %v", name, kt)
+			}
+			kv.SetUint(n)
+		}
+	}
+	ev := reflect.New(rv.Type().Elem()).Elem()
+	if err := u.unmarshal(value, ev); err != nil {
+		return err
+	}
+	rv.SetMapIndex(kv, ev)
+	return nil
+}
+
+// unmarshalQuoted unmarshals a value for a struct field with the ",string"
+// option, where a boolean, number, or string is encoded within a JSON string.
+func (u *unmarshaler) unmarshalQuoted(v *Value, rv reflect.Value) error {
+	lit, ok := v.Value.(Literal)
+	switch {
+	case ok && lit.Kind() == 'n':
+		return u.unmarshal(v, rv)
+	case !ok || lit.Kind() != '"':
+		return u.errorf(v, "invalid use of ,string struct tag, trying to unmarshal %s into %v", kindName(v.Value.Kind()), rv.Type())
+	}
+	inner := Literal(lit.String())
+	if k := inner.Kind(); !inner.IsValid() || k == '{' || k == '[' {
+		return u.errorf(v, "invalid use of ,string struct tag, trying to unmarshal %s into %v", lit, rv.Type())
+	}
+	v2 := *v
+	v2.Value = inner
+	return u.unmarshal(&v2, rv)
+}
+
+func (u *unmarshaler) unmarshalArray(v *Value, arr *Array, rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.Interface:
+		if rv.NumMethod() > 0 {
+			return u.typeError(v, rv.Type())
+		}
+		sv := reflect.New(reflect.TypeFor[[]any]()).Elem()
+		if err := u.unmarshalArray(v, arr, sv); err != nil {
+			return err
+		}
+		rv.Set(sv)
+		return nil
+	case reflect.Slice:
+		switch n := len(arr.Elements); {
+		case rv.IsNil() || rv.Cap() < n:
+			sv := reflect.MakeSlice(rv.Type(), n, n)
+			reflect.Copy(sv, rv)
+			rv.Set(sv)
+		default:
+			rv.SetLen(n)
+		}
+	case reflect.Array:
+		for i := len(arr.Elements); i < rv.Len(); i++ {
+			rv.Index(i).SetZero()
+		}
+	default:
+		return u.typeError(v, rv.Type())
+	}
+
+	for i := range arr.Elements {
+		if i >= rv.Len() {
+			break // ignore extra elements for Go arrays
+		}
+		n := len(u.pointer)
+		u.pointer = strconv.AppendInt(append(u.pointer, '/'), int64(i), 10)
+		err := u.unmarshal(&arr.Elements[i], rv.Index(i))
+		u.pointer = u.pointer[:n]
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldByIndex returns the nested field of rv at the provided index,
+// allocating any nil embedded pointers along the way.
+func fieldByIndex(rv reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				if !rv.CanSet() {
+					return rv, fmt.Errorf("cannot set embedded pointer to unexported struct: %v", rv.Type().Elem())
+				}
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv, nil
+}
+
+type structField struct {
+	name   string
+	index  []int
+	tagged bool
+	quoted bool
+}
+
+type structFields struct {
+	list   []structField
+	byName map[string]*structField
+}
+
+// lookup returns the field matching the name exactly, otherwise
+// it returns the first field matching the name case-insensitively.
+func (fs structFields) lookup(name string) *structField {
+	if f, ok := fs.byName[name]; ok {
+		return f
+	}
+	for i := range fs.list {
+		if strings.EqualFold(fs.list[i].name, name) {
+			return &fs.list[i]
+		}
+	}
+	return nil
+}
+
+var structFieldsCache sync.Map // map[reflect.Type]structFields
+
+func cachedStructFields(t reflect.Type) structFields {
+	if fs, ok := structFieldsCache.Load(t); ok {
+		return fs.(structFields)
+	}
+	fs, _ := structFieldsCache.LoadOrStore(t, makeStructFields(t))
+	return fs.(structFields)
+}
+
+// makeStructFields computes the set of fields for t according to the
+// same visibility rules as the encoding/json package.
+func makeStructFields(t reflect.Type) structFields {
+	type queueEntry struct {
+		typ   reflect.Type
+		index []int
+	}
+	var fields []structField
+	count := make(map[string]int)
+	visited := make(map[reflect.Type]bool)
+	var typeCount, nextTypeCount map[reflect.Type]int // embedded types per depth
+	next := []queueEntry{{typ: t}}
+	for len(next) > 0 {
+		current := next
+		next = nil
+		typeCount, nextTypeCount = nextTypeCount, make(map[reflect.Type]int)
+		var depthFields []structField
+		for _, qe := range current {
+			if visited[qe.typ] {
+				continue
+			}
+			visited[qe.typ] = true
+			for i := range qe.typ.NumField() {
+				sf := qe.typ.Field(i)
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if sf.Anonymous {
+					if !sf.IsExported() && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, opts, _ := strings.Cut(tag, ",")
+				index := append(append([]int(nil), qe.index...), i)
+
+				// Embedded structs without a name are promoted.
+				if name == "" && sf.Anonymous && ft.Kind() == reflect.Struct {
+					if nextTypeCount[ft]++; nextTypeCount[ft] == 1 {
+						next = append(next, queueEntry{ft, index})
+					}
+					continue
+				}
+
+				var quoted bool
+				for _, opt := range strings.Split(opts, ",") {
+					if opt == "string" {
+						switch ft.Kind() {
+						case reflect.Bool, reflect.String,
+							reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+							reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+							reflect.Float32, reflect.Float64:
+							quoted = true
+						}
+					}
+				}
+				f := structField{name: name, index: index, tagged: name != "", quoted: quoted}
+				if f.name == "" {
+					f.name = sf.Name
+				}
+				depthFields = append(depthFields, f)
+				if typeCount[qe.typ] > 1 {
+					// The same type embedded multiple times at this depth
+					// yields duplicate fields that annihilate each other.
+					depthFields = append(depthFields, f)
+				}
+			}
+		}
+
+		// Fields at a shallower depth dominate those at a deeper depth.
+		// Among fields at the same depth, a single tagged field dominates.
+		byName := make(map[string][]structField)
+		var order []string
+		for _, f := range depthFields {
+			if count[f.name] > 0 {
+				continue // dominated by shallower field
+			}
+			if byName[f.name] == nil {
+				order = append(order, f.name)
+			}
+			byName[f.name] = append(byName[f.name], f)
+		}
+		for _, name := range order {
+			fs := byName[name]
+			count[name]++
+			if len(fs) == 1 {
+				fields = append(fields, fs[0])
+				continue
+			}
+			var tagged []structField
+			for _, f := range fs {
+				if f.tagged {
+					tagged = append(tagged, f)
+				}
+			}
+			if len(tagged) == 1 {
+				fields = append(fields, tagged[0])
+			}
+		}
+	}
+
+	fs := structFields{list: fields, byName: make(map[string]*structField)}
+	for i := range fs.list {
+		fs.byName[fs.list[i].name] = &fs.list[i]
+	}
+	return fs
+}
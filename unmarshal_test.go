@@ -0,0 +1,204 @@
+// Copyright (c) 2021 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hujson
+
+import (
+	"encoding/json"
+	"errors"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type testPolicy struct {
+	ACLs      []testACL           `json:"acls"`
+	Groups    map[string][]string `json:"groups,omitempty"`
+	TagOwners map[string][]string
+	Hosts     map[string]netip.Addr `json:"hosts"`
+	Tests     json.RawMessage       `json:"tests"`
+	Version   json.Number           `json:"version"`
+	Extra     any                   `json:"extra"`
+	Ignored   string                `json:"-"`
+	testEmbedded
+}
+
+type testACL struct {
+	Action string   `json:"action"`
+	Users  []string `json:"users"`
+	Ports  []int    `json:"ports"`
+	Limit  int64    `json:"limit,string"`
+}
+
+type testEmbedded struct {
+	Embedded *bool `json:"embedded"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	in := `{
+	// Access control lists.
+	"acls": [
+		{"action": "accept", "users": ["alice", "bob",], "ports": [22, 80], "limit": "5"},
+		{"action": "deny", "users": [], "ports": null},
+	],
+	"groups": {"group:eng": ["alice"]},
+	"tagowners": {"tag:prod": ["group:eng"]}, /* case-insensitive match */
+	"hosts": {"router": "192.168.0.1"},
+	"tests": [ {"src": "alice"} ],
+	"version": 1.0,
+	"extra": {"k": [null, true, 3.5, "s"]},
+	"-": "ignored",
+	"embedded": true,
+}`
+	var got testPolicy
+	if err := Unmarshal([]byte(in), &got); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	std, err := Standardize([]byte(in))
+	if err != nil {
+		t.Fatalf("Standardize error: %v", err)
+	}
+	var want testPolicy
+	if err := json.Unmarshal(std, &want); err != nil {
+		t.Fatalf("json.Unmarshal error: %v", err)
+	}
+	want.Tests = json.RawMessage(`[{"src":"alice"}]`) // Unmarshal passes minimized JSON
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(testPolicy{}), cmp.Comparer(func(x, y netip.Addr) bool { return x == y })); diff != "" {
+		t.Errorf("Unmarshal mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		in      string
+		out     any
+		wantErr string
+		wantPtr string
+	}{{
+		in:      "{\n\t\"acls\": [\n\t\t{\"ports\": [22, \"80\"]},\n\t],\n}",
+		out:     new(testPolicy),
+		wantErr: "hujson: line 3, column 18 at /acls/0/ports/1: cannot unmarshal string into int",
+		wantPtr: "/acls/0/ports/1",
+	}, {
+		in:      `{"acls": {}}`,
+		out:     new(testPolicy),
+		wantErr: "hujson: line 1, column 10 at /acls: cannot unmarshal object into []hujson.testACL",
+		wantPtr: "/acls",
+	}, {
+		in:      `{"a/b": {"c~d": 300}}`,
+		out:     new(map[string]map[string]uint8),
+		wantErr: "hujson: line 1, column 17 at /a~1b/c~0d: cannot unmarshal number 300 into uint8",
+		wantPtr: "/a~1b/c~0d",
+	}, {
+		in:      `"hello"`,
+		out:     new(bool),
+		wantErr: "hujson: line 1, column 1: cannot unmarshal string into bool",
+	}, {
+		in:      `{"hosts": {"router": "not-an-ip"}}`,
+		out:     new(testPolicy),
+		wantErr: `hujson: line 1, column 22 at /hosts/router: ParseAddr("not-an-ip"): unable to parse IP`,
+		wantPtr: "/hosts/router",
+	}, {
+		in:      `{"acls": [{"limit": 5}]}`,
+		out:     new(testPolicy),
+		wantErr: "hujson: line 1, column 21 at /acls/0/limit: invalid use of ,string struct tag, trying to unmarshal number into int64",
+		wantPtr: "/acls/0/limit",
+	}}
+
+	for _, tt := range tests {
+		err := Unmarshal([]byte(tt.in), tt.out)
+		if err == nil || err.Error() != tt.wantErr {
+			t.Errorf("Unmarshal(%q) error:\ngot  %v\nwant %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		var uerr *UnmarshalError
+		if !errors.As(err, &uerr) {
+			t.Errorf("Unmarshal(%q) error type = %T, want *UnmarshalError", tt.in, err)
+		} else if uerr.Pointer != tt.wantPtr {
+			t.Errorf("Unmarshal(%q) error pointer = %q, want %q", tt.in, uerr.Pointer, tt.wantPtr)
+		}
+	}
+
+	var serr *SyntaxError
+	if err := Unmarshal([]byte(`{"k":}`), new(any)); !errors.As(err, &serr) {
+		t.Errorf("Unmarshal error = %v, want *SyntaxError", err)
+	}
+	if err := Unmarshal([]byte(`{}`), map[string]any(nil)); err == nil {
+		t.Errorf("Unmarshal into non-pointer error = nil, want non-nil")
+	}
+}
+
+func TestUnmarshalParity(t *testing.T) {
+	for _, tt := range testdata {
+		if tt.wantErr != nil {
+			continue
+		}
+		std, err := Standardize([]byte(tt.in))
+		if err != nil {
+			t.Fatalf("Standardize error: %v", err)
+		}
+		var want, got any
+		wantErr := json.Unmarshal(std, &want)
+		gotErr := Unmarshal([]byte(tt.in), &got)
+		if (wantErr == nil) != (gotErr == nil) {
+			t.Errorf("Unmarshal(%q) error = %v, want %v", tt.in, gotErr, wantErr)
+		}
+		if wantErr == nil && !reflect.DeepEqual(got, want) {
+			t.Errorf("Unmarshal(%q) = %v, want %v", tt.in, got, want)
+		}
+	}
+}
+
+type testInner struct{ X, Y int }
+type testOuterA struct{ testInner }
+type testOuterB struct{ testInner }
+
+type testFields struct {
+	testOuterA
+	testOuterB
+	Y       int   `json:"y"`
+	Int     *int  `json:"int,string"`
+	Bool    *bool `json:"bool,string"`
+	Str     *string
+	StrNull *string `json:",string"`
+}
+
+func TestUnmarshalFields(t *testing.T) {
+	in := `{"x": 1, "y": 2, "int": "3", "bool": "true", "str": "s", "strnull": null}`
+	var got, want testFields
+	if err := Unmarshal([]byte(in), &got); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if err := json.Unmarshal([]byte(in), &want); err != nil {
+		t.Fatalf("json.Unmarshal error: %v", err)
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(testFields{}, testOuterA{}, testOuterB{})); diff != "" {
+		t.Errorf("Unmarshal mismatch (-want +got):\n%s", diff)
+	}
+	if got.testOuterA.X != 0 || got.testOuterB.X != 0 {
+		t.Errorf("Unmarshal populated ambiguous embedded field X")
+	}
+	if got.Int == nil || *got.Int != 3 || got.Bool == nil || !*got.Bool {
+		t.Errorf("Unmarshal did not populate quoted pointer fields")
+	}
+}
+
+func TestUnmarshalDeepNesting(t *testing.T) {
+	const depth = 1 << 20
+	in := strings.Repeat("[", depth) + strings.Repeat("]", depth)
+	var got any
+	err := Unmarshal([]byte(in), &got)
+	var uerr *UnmarshalError
+	if !errors.As(err, &uerr) || uerr.Offset != maxUnmarshalDepth {
+		t.Fatalf("Unmarshal error = %v, want UnmarshalError at offset %d", err, maxUnmarshalDepth)
+	}
+
+	in = strings.Repeat("[", maxUnmarshalDepth) + strings.Repeat("]", maxUnmarshalDepth)
+	if err := Unmarshal([]byte(in), &got); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+}
@@ -0,0 +1,346 @@
+// Copyright (c) 2021 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hujson
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// UpdateFrom updates the value to match the JSON representation of goValue
+// (as marshaled by json.Marshal) while preserving comments.
+//
+// It applies a minimal set of changes such that members and elements that
+// are semantically unchanged are left untouched, along with their comments.
+// Object members that are added are appended to the end of the object,
+// and object members or array elements that are removed
+// take their associated comments with them (see Patch).
+// Values that change kind are replaced in place, preserving the
+// surrounding comments.
+//
+// It does not format the value. It is recommended that Format be called after
+// updating a value.
+func (v *Value) UpdateFrom(goValue any) error {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(goValue); err != nil {
+		return fmt.Errorf("hujson: %w", err)
+	}
+	want, err := Parse(b.Bytes())
+	if err != nil {
+		return err
+	}
+	want.Minimize()
+	v.update(want.Value)
+	return nil
+}
+
+// update updates v to be semantically equal to want,
+// which must not have any whitespace or comments.
+func (v *Value) update(want ValueTrimmed) {
+	switch got := v.Value.(type) {
+	case Literal:
+		if want, ok := want.(Literal); ok && equalLiteral(got, want) {
+			return
+		}
+	case *Object:
+		if want, ok := want.(*Object); ok {
+			got.update(want)
+			return
+		}
+	case *Array:
+		if want, ok := want.(*Array); ok {
+			got.update(want)
+			return
+		}
+	}
+	v.Value = want
+}
+
+func (obj *Object) update(want *Object) {
+	wantIndexes := make(map[string]int)
+	for i, m := range want.Members {
+		wantIndexes[m.Name.Value.(Literal).String()] = i
+	}
+
+	// Update or remove existing members.
+	// Duplicate names cannot be represented in Go, so they are removed.
+	seen := make(map[string]bool)
+	for i := 0; i < len(obj.Members); {
+		name := obj.Members[i].Name.Value.(Literal).String()
+		j, ok := wantIndexes[name]
+		if !ok || seen[name] {
+			removeAt(obj, i)
+			continue
+		}
+		seen[name] = true
+		obj.Members[i].Value.update(want.Members[j].Value.Value)
+		i++
+	}
+
+	// Append new members.
+	for _, m := range want.Members {
+		if !seen[m.Name.Value.(Literal).String()] {
+			insertAt(obj, obj.length(), Value{Value: m.Value.Value})
+			obj.Members[obj.length()-1].Name.Value = m.Name.Value
+		}
+	}
+}
+
+func (arr *Array) update(want *Array) {
+	// Compute the longest common subsequence of unchanged elements.
+	// Elements between unchanged elements are updated in place where possible,
+	// such that comments stay with the element at the same relative position.
+	gotKeys := minimizedKeys(arr.Elements)
+	wantKeys := make([]string, len(want.Elements))
+	for i, e := range want.Elements {
+		wantKeys[i] = string(e.Pack())
+	}
+	lcs := longestCommonSubsequence(gotKeys, wantKeys)
+
+	var pos, i0, j0 int // pos is the index into arr.Elements for gotKeys[i0]
+	for _, match := range append(lcs, [2]int{len(gotKeys), len(wantKeys)}) {
+		// Process the gap of changed elements before the next unchanged element,
+		// where gotKeys[i0:match[0]] is replaced by wantKeys[j0:match[1]].
+		numGot, numWant := match[0]-i0, match[1]-j0
+		n := min(numGot, numWant)
+		for k := range n {
+			arr.Elements[pos+k].update(want.Elements[j0+k].Value)
+		}
+		for range numGot - n {
+			removeAt(arr, pos+n)
+		}
+		for k := n; k < numWant; k++ {
+			insertAt(arr, pos+k, Value{Value: want.Elements[j0+k].Value})
+		}
+		pos += numWant + 1 // skip past the unchanged element
+		i0, j0 = match[0]+1, match[1]+1
+	}
+}
+
+// minimizedKeys returns the minimized representation of each value,
+// which is suitable for comparing values for exact equality.
+func minimizedKeys(vs []Value) []string {
+	keys := make([]string, len(vs))
+	for i, v := range vs {
+		v = v.Clone()
+		v.Minimize()
+		keys[i] = string(v.Pack())
+	}
+	return keys
+}
+
+// longestCommonSubsequence returns the index pairs of x and y
+// that form a longest common subsequence.
+//
+// It uses the linear-space variant of the algorithm by Eugene W. Myers,
+// "An O(ND) Difference Algorithm and Its Variations" (1986),
+// which takes O((N+M)·D) time for N and M elements with D differences.
+// Elements present in only one of x or y cannot be part of the subsequence
+// and are discarded beforehand.
+func longestCommonSubsequence(x, y []string) (pairs [][2]int) {
+	// Intern each element that is present in both x and y.
+	ids := make(map[string]int)
+	for _, k := range x {
+		ids[k] = 0
+	}
+	var numIDs int
+	for _, k := range y {
+		if id, ok := ids[k]; ok && id == 0 {
+			numIDs++
+			ids[k] = numIDs
+		}
+	}
+	var l lcs
+	for i, k := range x {
+		if id := ids[k]; id > 0 {
+			l.a, l.ai = append(l.a, id), append(l.ai, i)
+		}
+	}
+	for j, k := range y {
+		if id := ids[k]; id > 0 {
+			l.b, l.bj = append(l.b, id), append(l.bj, j)
+		}
+	}
+	l.compare(0, len(l.a), 0, len(l.b))
+	return l.pairs
+}
+
+// lcs computes the longest common subsequence of a and b,
+// which are the interned elements at indexes ai and bj of the original input.
+type lcs struct {
+	a, b   []int
+	ai, bj []int
+	vf, vb []int // furthest reaching x for each diagonal, forward and backward
+	pairs  [][2]int
+}
+
+// compare appends the pairs of the longest common subsequence
+// of a[a0:a1] and b[b0:b1].
+func (l *lcs) compare(a0, a1, b0, b1 int) {
+	// Match the common prefix.
+	for a0 < a1 && b0 < b1 && l.a[a0] == l.b[b0] {
+		l.match(a0, b0)
+		a0, b0 = a0+1, b0+1
+	}
+	// Match the common suffix after the remainder.
+	var n int
+	for a0 < a1-n && b0 < b1-n && l.a[a1-n-1] == l.b[b1-n-1] {
+		n++
+	}
+	if a0 < a1-n && b0 < b1-n {
+		x0, y0, x1, y1 := l.middleSnake(a0, a1-n, b0, b1-n)
+		l.compare(a0, x0, b0, y0)
+		for x, y := x0, y0; x < x1; x, y = x+1, y+1 {
+			l.match(x, y)
+		}
+		l.compare(x1, a1-n, y1, b1-n)
+	}
+	for i := range n {
+		l.match(a1-n+i, b1-n+i)
+	}
+}
+
+func (l *lcs) match(i, j int) {
+	l.pairs = append(l.pairs, [2]int{l.ai[i], l.bj[j]})
+}
+
+// middleSnake finds the middle snake of an optimal path
+// from (a0, b0) to (a1, b1), which is the diagonal from (x0, y0) to (x1, y1).
+func (l *lcs) middleSnake(a0, a1, b0, b1 int) (x0, y0, x1, y1 int) {
+	n, m := a1-a0, b1-b0
+	delta := n - m
+	odd := delta%2 != 0
+	dmax := (n + m + 1) / 2
+	size := 2*dmax + 2
+	if cap(l.vf) < size {
+		l.vf, l.vb = make([]int, size), make([]int, size)
+	}
+	vf, vb := l.vf[:size], l.vb[:size]
+	off := dmax // vf[off+k] is the furthest x on diagonal k = x - y
+	vf[off+1], vb[off+1] = 0, 0
+	for d := 0; d <= dmax; d++ {
+		// Search forward from (a0, b0).
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && vf[off+k-1] < vf[off+k+1]) {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+			y := x - k
+			xs, ys := x, y
+			for x < n && y < m && l.a[a0+x] == l.b[b0+y] {
+				x, y = x+1, y+1
+			}
+			vf[off+k] = x
+			if kr := delta - k; odd && -d < kr && kr < d && x+vb[off+kr] >= n {
+				return a0 + xs, b0 + ys, a0 + x, b0 + y
+			}
+		}
+		// Search backward from (a1, b1), where x and y count from the end.
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && vb[off+k-1] < vb[off+k+1]) {
+				x = vb[off+k+1]
+			} else {
+				x = vb[off+k-1] + 1
+			}
+			y := x - k
+			xs, ys := x, y
+			for x < n && y < m && l.a[a1-1-x] == l.b[b1-1-y] {
+				x, y = x+1, y+1
+			}
+			vb[off+k] = x
+			if kf := delta - k; !odd && -d <= kf && kf <= d && x+vf[off+kf] >= n {
+				return a1 - x, b1 - y, a1 - xs, b1 - ys
+			}
+		}
+	}
+	panic("unreachable")
+}
+
+// equalLiteral reports whether two literals are semantically equal.
+// Numbers are compared exactly and strings are compared by their
+// exact decoding, such that unpaired surrogates and invalid UTF-8
+// are not conflated with the Unicode replacement character.
+func equalLiteral(x, y Literal) bool {
+	switch {
+	case bytes.Equal(x, y):
+		return true
+	case x.Kind() != y.Kind():
+		return false
+	case x.Kind() == '0':
+		rx, okx := x.Rat()
+		ry, oky := y.Rat()
+		return okx && oky && rx.Cmp(ry) == 0
+	case x.Kind() == '"':
+		return slices.Equal(x.decodeExact(), y.decodeExact())
+	default:
+		return false
+	}
+}
+
+// decodeExact decodes a valid JSON string literal as a sequence of
+// code points, where unpaired surrogates are preserved as is and
+// each byte of invalid UTF-8 is represented as its negated value.
+func (b Literal) decodeExact() (rs []rune) {
+	b = b[len(`"`) : len(b)-len(`"`)]
+	for i := 0; i < len(b); {
+		r, n := utf8.DecodeRune(b[i:])
+		switch {
+		case r == utf8.RuneError && n == 1:
+			r = -rune(b[i])
+		case b[i] == '\\' && b.parseEscape(i) >= 0:
+			r, n = b.parseEscape(i), len(`\uXXXX`)
+			if r2 := utf16.DecodeRune(r, b.parseEscape(i+n)); utf16.IsSurrogate(r) && r2 != utf8.RuneError {
+				r, n = r2, 2*len(`\uXXXX`)
+			}
+		case b[i] == '\\' && i+1 < len(b):
+			r, n = rune(b[i+1]), len(`\x`)
+			switch r {
+			case 'b':
+				r = '\b'
+			case 'f':
+				r = '\f'
+			case 'n':
+				r = '\n'
+			case 'r':
+				r = '\r'
+			case 't':
+				r = '\t'
+			}
+		}
+		rs = append(rs, r)
+		i += n
+	}
+	return rs
+}
+
+// exactKey returns the exact decoding of a valid JSON string literal
+// as a string suitable for use as a map key.
+// It is the UTF-8 encoding of the string, except that each unpaired surrogate
+// or byte of invalid UTF-8 is encoded as 0xff followed by its 32-bit value,
+// which never occurs in valid UTF-8.
+func (b Literal) exactKey() string {
+	if s := b[len(`"`) : len(b)-len(`"`)]; bytes.IndexByte(s, '\\') < 0 && utf8.Valid(s) {
+		return string(s)
+	}
+	var key []byte
+	for _, r := range b.decodeExact() {
+		if utf8.ValidRune(r) {
+			key = utf8.AppendRune(key, r)
+		} else {
+			key = binary.BigEndian.AppendUint32(append(key, 0xff), uint32(r))
+		}
+	}
+	return string(key)
+}
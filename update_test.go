@@ -0,0 +1,216 @@
+// Copyright (c) 2021 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hujson
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUpdateFrom(t *testing.T) {
+	tests := []struct {
+		in   string
+		from any
+		want string // formatted output
+	}{{
+		in:   `null`,
+		from: map[string]int{"a": 1},
+		want: `{"a": 1}`,
+	}, {
+		in:   `/* leading */ 1.0 /* trailing */`,
+		from: 1,
+		want: `/* leading */ 1.0 /* trailing */`,
+	}, {
+		in:   `/* leading */ "<old>" /* trailing */`,
+		from: "<new>",
+		want: `/* leading */ "<new>" /* trailing */`,
+	}, {
+		in: `{
+	// Comment for name1.
+	"name1": "value1",
+	// Comment for name2.
+	"name2": "value2", // Trailing comment for name2.
+	// Comment for name3.
+	"name3": [
+		// Comment for element1.
+		"element1",
+		// Comment for element2.
+		"element2",
+		// Comment for element3.
+		"element3",
+	],
+}`,
+		from: map[string]any{
+			"name1": "value1",
+			"name3": []string{"element1", "element3", "element4"},
+			"name4": map[string]int{"k": 5},
+		},
+		want: `{
+	// Comment for name1.
+	"name1": "value1",
+	// Comment for name3.
+	"name3": [
+		// Comment for element1.
+		"element1",
+		// Comment for element3.
+		"element3",
+		"element4",
+	],
+	"name4": {"k": 5},
+}`,
+	}, {
+		in: `[
+	// Comment for first.
+	{"name": "first", "value": 1},
+	// Comment for second.
+	{"name": "second", "value": 2},
+]`,
+		from: []map[string]any{
+			{"name": "first", "value": 1},
+			{"name": "second", "value": 3},
+		},
+		want: `[
+	// Comment for first.
+	{"name": "first", "value": 1},
+	// Comment for second.
+	{"name": "second", "value": 3},
+]`,
+	}}
+
+	for _, tt := range tests {
+		v, err := Parse([]byte(tt.in))
+		if err != nil {
+			t.Fatalf("Parse error: %v", err)
+		}
+		if err := v.UpdateFrom(tt.from); err != nil {
+			t.Fatalf("UpdateFrom error: %v", err)
+		}
+		v.Format()
+		got := v.String()
+		want := tt.want + "\n"
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("UpdateFrom mismatch (-want +got):\n%s", diff)
+		}
+	}
+
+	var v Value
+	if err := v.UpdateFrom(make(chan int)); err == nil {
+		t.Errorf("UpdateFrom(chan) error = nil, want non-nil")
+	}
+}
+
+func TestUpdateFromExact(t *testing.T) {
+	// Literals are compared exactly, without going through float64
+	// or replacing unpaired surrogates with the replacement character.
+	tests := []struct {
+		in   string
+		from any
+		want string
+	}{
+		{in: `[9007199254740992, 1e2]`, from: []uint64{9007199254740993, 100}, want: `[9007199254740993, 1e2]`},
+		{in: `["\ud800", "\u00e9", "\ufffd"]`, from: []string{"\ufffd", "\u00e9", "\ufffd"}, want: "[\"\ufffd\", \"\\u00e9\", \"\\ufffd\"]"},
+		{in: `"\udc00"`, from: "\ufffd", want: "\"\ufffd\""},
+	}
+	for _, tt := range tests {
+		v, err := Parse([]byte(tt.in))
+		if err != nil {
+			t.Fatalf("Parse error: %v", err)
+		}
+		if err := v.UpdateFrom(tt.from); err != nil {
+			t.Fatalf("UpdateFrom error: %v", err)
+		}
+		if got := v.String(); got != tt.want {
+			t.Errorf("UpdateFrom(%v) = %s, want %s", tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestLongestCommonSubsequence(t *testing.T) {
+	// lcsLength computes the length using a quadratic-space reference.
+	lcsLength := func(x, y []string) int {
+		lengths := make([][]int, len(x)+1)
+		for i := range lengths {
+			lengths[i] = make([]int, len(y)+1)
+		}
+		for i := len(x) - 1; i >= 0; i-- {
+			for j := len(y) - 1; j >= 0; j-- {
+				if x[i] == y[j] {
+					lengths[i][j] = lengths[i+1][j+1] + 1
+				} else {
+					lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+				}
+			}
+		}
+		return lengths[0][0]
+	}
+	random := func(r *rand.Rand) []string {
+		s := make([]string, r.IntN(20))
+		for i := range s {
+			s[i] = string(rune('a' + r.IntN(5)))
+		}
+		return s
+	}
+
+	r := rand.New(rand.NewPCG(0, 0))
+	for range 10000 {
+		x, y := random(r), random(r)
+		pairs := longestCommonSubsequence(x, y)
+		for k, p := range pairs {
+			if x[p[0]] != y[p[1]] || (k > 0 && (p[0] <= pairs[k-1][0] || p[1] <= pairs[k-1][1])) {
+				t.Fatalf("longestCommonSubsequence(%q, %q) = %v, want increasing pairs of equal elements", x, y, pairs)
+			}
+		}
+		if got, want := len(pairs), lcsLength(x, y); got != want {
+			t.Fatalf("longestCommonSubsequence(%q, %q) has length %d, want %d", x, y, got, want)
+		}
+	}
+}
+
+func BenchmarkUpdateFrom(b *testing.B) {
+	type entry struct {
+		Name  string `json:"name"`
+		Value int    `json:"value"`
+	}
+	const n = 10000
+	var in []byte
+	in = append(in, "[\n"...)
+	for i := range n {
+		in = fmt.Appendf(in, "\t{\"name\": \"entry%d\", \"value\": %d}, // Comment\n", i, i)
+	}
+	in = append(in, "]\n"...)
+	v, err := Parse(in)
+	if err != nil {
+		b.Fatalf("Parse error: %v", err)
+	}
+
+	edited := make([]entry, n)
+	for i := range edited {
+		edited[i] = entry{fmt.Sprintf("entry%d", i), i}
+	}
+	edited = slices.Delete(edited, 10, 20)                 // remove some entries
+	edited = slices.Insert(edited, 5000, entry{"new", -1}) // insert an entry
+	edited[n/2+100].Value = -1                             // modify an entry
+	reversed := slices.Clone(edited)
+	slices.Reverse(reversed)
+
+	for _, bb := range []struct {
+		name string
+		want []entry
+	}{{"Edited", edited}, {"Reversed", reversed}} {
+		b.Run(bb.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				v2 := v.Clone()
+				if err := v2.UpdateFrom(bb.want); err != nil {
+					b.Fatalf("UpdateFrom error: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+// Copyright (c) 2021 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hujson
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// byteOrderMark is the UTF-8 encoding of U+FEFF.
+var byteOrderMark = []byte("\ufeff")
+
+var errInvalidUTF8 = errors.New("invalid UTF-8")
+
+// Problem describes a problem within a value.
+type Problem struct {
+	// Pointer is the JSON pointer (RFC 6901) to the value containing
+	// the problem. For a problem in an object member name,
+	// it is the pointer to the member.
+	// A JSON pointer is a Unicode string, so invalid UTF-8 and
+	// unpaired surrogates in member names are replaced by U+FFFD
+	// (as with Walk and Find), which may make the pointer ambiguous.
+	// Position identifies the problem exactly.
+	Pointer string
+	// Position is the position of the problem.
+	Position Position
+	// Err describes the problem.
+	Err error
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("line %d, column %d: %q: %v", p.Position.Line, p.Position.Column, p.Pointer, p.Err)
+}
+
+// Validate reports every problem with the Unicode content of v,
+// in the order that they appear. It reports strings (including object names)
+// and comments that contain invalid UTF-8, strings that contain
+// an escaped surrogate that is not part of a valid pair (e.g., "\uD800"),
+// and a leading byte order mark.
+// Values parsed with ParseOptions.RejectInvalidUnicode have no such problems.
+//
+// Positions are relative to the output of Pack and rely on the StartOffset
+// of each value, so the result is only meaningful if v has not been modified
+// since parsing or UpdateOffsets has been called since then.
+func (v Value) Validate() []Problem {
+	return v.validate(v.Pack())
+}
+func (v *Value) validate(b []byte) (probs []Problem) {
+	report := func(pointer string, n int, err error) {
+		line, column := lineColumn(b, min(max(n, 0), len(b)))
+		probs = append(probs, Problem{
+			Pointer:  pointer,
+			Position: Position{Offset: int64(n), Line: line, Column: column},
+			Err:      err,
+		})
+	}
+	checkExtra := func(pointer string, extra Extra, n int) {
+		for i := consumeWhitespace(extra); i < len(extra); i += consumeWhitespace(extra[i:]) {
+			nc := consumeComment(extra[i:])
+			if nc <= 0 {
+				return // invalid extra, which is not a Unicode problem
+			}
+			if j := invalidUTF8Index(extra[i : i+nc]); j >= 0 {
+				report(pointer, n+i+j, fmt.Errorf("%w in comment", errInvalidUTF8))
+			}
+			i += nc
+		}
+	}
+	checkValue := func(pointer string, v2 *Value) {
+		checkExtra(pointer, v2.BeforeExtra, v2.StartOffset-len(v2.BeforeExtra))
+		if lit, ok := v2.Value.(Literal); ok && lit.Kind() == '"' {
+			if i, err := lit.invalidUnicode(); err != nil {
+				report(pointer, v2.StartOffset+i, fmt.Errorf("%w in string", err))
+			}
+		}
+		if comp, ok := v2.Value.(composite); ok {
+			extra := *comp.afterExtra()
+			checkExtra(pointer, extra, v2.EndOffset-len("]")-len(extra))
+		}
+		checkExtra(pointer, v2.AfterExtra, v2.EndOffset)
+	}
+
+	if bytes.HasPrefix(v.BeforeExtra, byteOrderMark) {
+		report("", 0, errors.New("byte order mark"))
+	}
+	for p, v2 := range v.Walk() {
+		checkValue(p.Pointer, v2)
+		if obj, ok := v2.Value.(*Object); ok {
+			for i := range obj.Members {
+				m := &obj.Members[i]
+				checkValue(string(appendPointerToken([]byte(p.Pointer), m.Name.Value.(Literal).String())), &m.Name)
+			}
+		}
+	}
+	slices.SortStableFunc(probs, func(x, y Problem) int {
+		return cmp.Compare(x.Position.Offset, y.Position.Offset)
+	})
+	return probs
+}
+
+// CheckIJSON reports every way in which v does not conform to
+// I-JSON (RFC 7493), in the order that they appear.
+// It reports the problems reported by Validate and DuplicateNames,
+// strings (including object names) that contain Unicode noncharacters,
+// numbers that exceed the range of an IEEE 754 double, and
+// integers that cannot be exactly represented by an IEEE 754 double
+// (i.e., outside the range of -(2^53)+1 to (2^53)-1).
+// Comments and whitespace are permitted since they are removed by Standardize.
+//
+// Positions are relative to the output of Pack and rely on the StartOffset
+// of each value, so the result is only meaningful if v has not been modified
+// since parsing or UpdateOffsets has been called since then.
+func (v Value) CheckIJSON() []Problem {
+	b := v.Pack()
+	probs := v.validate(b)
+	report := func(pointer string, n int, err error) {
+		line, column := lineColumn(b, min(max(n, 0), len(b)))
+		probs = append(probs, Problem{
+			Pointer:  pointer,
+			Position: Position{Offset: int64(n), Line: line, Column: column},
+			Err:      err,
+		})
+	}
+	for _, d := range v.duplicateNames(b) {
+		report(d.Pointer, int(d.Duplicate.Offset), fmt.Errorf("duplicate object name %q (first at line %d, column %d)", d.Name, d.First.Line, d.First.Column))
+	}
+	checkLiteral := func(pointer string, v2 *Value) {
+		lit, ok := v2.Value.(Literal)
+		if !ok {
+			return
+		}
+		switch lit.Kind() {
+		case '"':
+			if i, r := lit.noncharacter(); i >= 0 {
+				report(pointer, v2.StartOffset+i, fmt.Errorf("noncharacter %U in string", r))
+			}
+		case '0':
+			if err := lit.checkIJSONNumber(); err != nil {
+				report(pointer, v2.StartOffset, err)
+			}
+		}
+	}
+	for p, v2 := range v.Walk() {
+		checkLiteral(p.Pointer, v2)
+		if obj, ok := v2.Value.(*Object); ok {
+			for i := range obj.Members {
+				m := &obj.Members[i]
+				checkLiteral(string(appendPointerToken([]byte(p.Pointer), m.Name.Value.(Literal).String())), &m.Name)
+			}
+		}
+	}
+	slices.SortStableFunc(probs, func(x, y Problem) int {
+		return cmp.Compare(x.Position.Offset, y.Position.Offset)
+	})
+	return probs
+}
+
+// checkIJSONNumber reports whether a valid JSON number is within
+// the limits of I-JSON (RFC 7493, section 2.2).
+func (b Literal) checkIJSONNumber() error {
+	const maxSafeInteger = 1<<53 - 1
+	if bytes.ContainsAny(b, ".eE") {
+		if _, err := strconv.ParseFloat(string(b), 64); err != nil {
+			return fmt.Errorf("number %s exceeds the range of an IEEE 754 double", string(b))
+		}
+		return nil
+	}
+	if n, err := strconv.ParseInt(string(b), 10, 64); err != nil || n < -maxSafeInteger || n > maxSafeInteger {
+		return fmt.Errorf("integer %s is not exactly representable as an IEEE 754 double", string(b))
+	}
+	return nil
+}
+
+// noncharacter reports the offset and value of the first Unicode noncharacter
+// (e.g., U+FFFE) in a valid JSON string literal, whether escaped or not.
+// It returns a negative offset if there is none.
+func (b Literal) noncharacter() (int, rune) {
+	isNoncharacter := func(r rune) bool {
+		return (0xFDD0 <= r && r <= 0xFDEF) || (r >= 0 && r&0xFFFE == 0xFFFE)
+	}
+	for i := 0; i < len(b); {
+		r, n := utf8.DecodeRune(b[i:])
+		if b[i] == '\\' {
+			n = len(`\x`)
+			if r = b.parseEscape(i); r >= 0 {
+				n = len(`\uXXXX`)
+				if r2 := b.parseEscape(i + n); utf16.IsSurrogate(r) && r2 >= 0 {
+					if r3 := utf16.DecodeRune(r, r2); r3 != utf8.RuneError {
+						r, n = r3, 2*len(`\uXXXX`)
+					}
+				}
+			}
+		}
+		if isNoncharacter(r) {
+			return i, r
+		}
+		i += n
+	}
+	return -1, 0
+}
+
+// invalidUnicode reports the offset and description of the first problem
+// in a valid JSON string literal that is either invalid UTF-8 or
+// an escaped surrogate that is unpaired. It returns a nil error if none.
+func (b Literal) invalidUnicode() (int, error) {
+	if i := invalidUTF8Index(b); i >= 0 {
+		return i, errInvalidUTF8
+	}
+	for i := 0; i < len(b); i++ {
+		if b[i] != '\\' {
+			continue
+		}
+		r := b.parseEscape(i)
+		if r < 0 {
+			i++ // skip the escaped character
+			continue
+		}
+		if utf16.IsSurrogate(r) {
+			// Only a high surrogate followed by a low surrogate is valid.
+			r2 := b.parseEscape(i + len(`\uXXXX`))
+			if utf16.DecodeRune(r, r2) == utf8.RuneError {
+				return i, fmt.Errorf("unpaired surrogate %s", b[i:i+len(`\uXXXX`)])
+			}
+			i += len(`\uXXXX`)
+		}
+		i += len(`\uXXXX`) - 1
+	}
+	return -1, nil
+}
+
+// isUnicodeValid reports whether a valid JSON string literal
+// is valid UTF-8 without any unpaired surrogates.
+func (b Literal) isUnicodeValid() bool {
+	_, err := b.invalidUnicode()
+	return err == nil
+}
+
+// parseEscape parses the "\uXXXX" escape sequence at offset i in b,
+// returning -1 if there is no such escape sequence.
+func (b Literal) parseEscape(i int) rune {
+	if len(b) < i+len(`\uXXXX`) || b[i] != '\\' || b[i+1] != 'u' {
+		return -1
+	}
+	r, err := strconv.ParseUint(string(b[i+2:i+6]), 16, 16)
+	if err != nil {
+		return -1
+	}
+	return rune(r)
+}
+
+// invalidUTF8Index returns the offset of the first invalid UTF-8 in b,
+// or -1 if b is valid UTF-8.
+func invalidUTF8Index(b []byte) int {
+	for i := 0; i < len(b); {
+		r, n := utf8.DecodeRune(b[i:])
+		if r == utf8.RuneError && n == 1 {
+			return i
+		}
+		i += n
+	}
+	return -1
+}
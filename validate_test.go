@@ -0,0 +1,104 @@
+// Copyright (c) 2021 Tailscale Inc & AUTHORS All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hujson
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValidate(t *testing.T) {
+	in := "{\n\t\"a\xff\": \"\\ud800\",\n\t\"b\": [\"\\ud83d\\ude00\", \"\\udc00\\ud800\"],\n}"
+	v, err := Parse([]byte(in))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	v.Value.(*Object).Members[1].Value.Value.(*Array).Elements[0].AfterExtra = Extra("/*\xff*/")
+	v.BeforeExtra = Extra("\ufeff")
+	v.UpdateOffsets()
+
+	var got []string
+	for _, p := range v.Validate() {
+		got = append(got, p.String())
+		if v.Find(p.Pointer) == nil {
+			t.Errorf("Find(%q) = nil, want value", p.Pointer)
+		}
+	}
+	want := []string{
+		`line 1, column 1: "": byte order mark`,
+		"line 2, column 4: \"/a\ufffd\": invalid UTF-8 in string",
+		"line 2, column 9: \"/a\ufffd\": unpaired surrogate \\ud800 in string",
+		`line 3, column 24: "/b/0": invalid UTF-8 in comment`,
+		`line 3, column 30: "/b/1": unpaired surrogate \udc00 in string`,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Validate mismatch (-want +got):\n%s", diff)
+	}
+	if got := (Value{Value: Literal("\"\\ud83d\\ude00\U0001F600\"")}).Validate(); len(got) > 0 {
+		t.Errorf("Validate = %v, want none", got)
+	}
+}
+
+func TestParseInvalidUnicode(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantErr string
+		wantOff int64
+	}{
+		{in: `["\ud83d\ude00", "\u0000", "\\ud800"]`},
+		{in: "[\"\xff\"]", wantErr: `hujson: line 1, column 3: invalid UTF-8 in string`, wantOff: 2},
+		{in: `{"\ud800": 0}`, wantErr: `hujson: line 1, column 3: unpaired surrogate \ud800 in string`, wantOff: 2},
+		{in: `"\ude00\ud83d"`, wantErr: `hujson: line 1, column 2: unpaired surrogate \ude00 in string`, wantOff: 1},
+		{in: "\ufeff0", wantErr: `hujson: line 1, column 1: byte order mark is not permitted`, wantOff: 0},
+	}
+	for _, tt := range tests {
+		if _, err := Parse([]byte(tt.in)); err != nil && tt.in[0] != '\xef' {
+			t.Errorf("Parse(%q) error: %v", tt.in, err)
+		}
+		_, err := ParseWithOptions([]byte(tt.in), ParseOptions{RejectInvalidUnicode: true})
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("ParseWithOptions(%q) error: %v", tt.in, err)
+			}
+			continue
+		}
+		var serr *SyntaxError
+		if !errors.As(err, &serr) || err.Error() != tt.wantErr || serr.Offset != tt.wantOff {
+			t.Errorf("ParseWithOptions(%q) error = %v, want %v", tt.in, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckIJSON(t *testing.T) {
+	in := "{\n" +
+		"\t\"id\": 9007199254740993, // exceeds 2^53\n" +
+		"\t\"ok\": [9007199254740991, -9007199254740991, 1e300, 0.1, \"\U0001F600\", \"\\ud83d\\ude00\"],\n" +
+		"\t\"id\": \"\\uFFFE\",\n" +
+		"\t\"big\": [1e400, -9007199254740992],\n" +
+		"\t\"\\uDBFF\\uDFFF\": \"\\u0000\uFDD0\",\n" +
+		"}"
+	v, err := Parse([]byte(in))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	var got []string
+	for _, p := range v.CheckIJSON() {
+		got = append(got, p.String())
+	}
+	want := []string{
+		`line 2, column 8: "/id": integer 9007199254740993 is not exactly representable as an IEEE 754 double`,
+		`line 4, column 2: "/id": duplicate object name "id" (first at line 2, column 2)`,
+		`line 4, column 9: "/id": noncharacter U+FFFE in string`,
+		`line 5, column 10: "/big/0": number 1e400 exceeds the range of an IEEE 754 double`,
+		`line 5, column 17: "/big/1": integer -9007199254740992 is not exactly representable as an IEEE 754 double`,
+		`line 6, column 3: "/\U0010ffff": noncharacter U+10FFFF in string`,
+		`line 6, column 25: "/\U0010ffff": noncharacter U+FDD0 in string`,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("CheckIJSON mismatch (-want +got):\n%s", diff)
+	}
+}